@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// deviceID is unsupported off unix, so --one-file-system has no effect there.
+func deviceID(path string) (uint64, bool) {
+	return 0, false
+}
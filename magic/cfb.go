@@ -0,0 +1,510 @@
+package magic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Compound File Binary (OLE2) layout, from [MS-CFB].
+const (
+	cfbHeaderSize   = 512
+	cfbDirEntrySize = 128
+	cfbMaxSector    = 0xFFFFFFFA
+
+	cfbTypeStorage = 1
+	cfbTypeStream  = 2
+	cfbTypeRoot    = 5
+
+	// maxCFBDirEntries bounds the directory we load.
+	maxCFBDirEntries = 1 << 16
+)
+
+var errCFBCorrupt = errors.New("corrupt compound file")
+
+type cfbEntry struct {
+	name  string
+	typ   byte
+	left  uint32
+	right uint32
+	child uint32
+	clsid [16]byte
+	start uint32
+	size  uint64
+}
+
+// cfbFile is an opened compound file: its allocation tables and directory.
+type cfbFile struct {
+	b          []byte
+	file       *source
+	sectorSize int
+	miniCutoff uint64
+	fat        []uint32
+	miniFAT    []uint32
+	miniChain  []uint32 // root entry's chain, which holds the mini stream
+	dir        []cfbEntry
+}
+
+func openCFB(b []byte, file *source) (*cfbFile, error) {
+	hdr, ok := readAt(b, file, 0, cfbHeaderSize)
+	if !ok {
+		return nil, errCFBCorrupt
+	}
+	c := &cfbFile{b: b, file: file}
+	switch shift := peekLe(hdr[30:], 2); {
+	case shift == 9 && peekLe(hdr[26:], 2) == 3, shift == 12 && peekLe(hdr[26:], 2) == 4:
+		c.sectorSize = 1 << shift
+	default:
+		return nil, errCFBCorrupt
+	}
+	if peekLe(hdr[28:], 2) != 0xFFFE || peekLe(hdr[32:], 2) != 6 {
+		return nil, errCFBCorrupt
+	}
+	c.miniCutoff = uint64(peekLe(hdr[56:], 4))
+
+	// The FAT sectors are listed by the header's 109 DIFAT slots and then a
+	// chain of DIFAT sectors, each ending with the next one's number.
+	numFAT := peekLe(hdr[44:], 4)
+	if numFAT > c.sectors()+1 {
+		return nil, errCFBCorrupt
+	}
+	var fatSectors []uint32
+	for i := 0; i < 109 && len(fatSectors) < numFAT; i++ {
+		fatSectors = append(fatSectors, uint32(peekLe(hdr[76+4*i:], 4)))
+	}
+	next := uint32(peekLe(hdr[68:], 4))
+	for hops := 0; len(fatSectors) < numFAT && next <= cfbMaxSector; hops++ {
+		sector, ok := c.sector(next)
+		if !ok || hops > numFAT {
+			return nil, errCFBCorrupt
+		}
+		per := c.sectorSize/4 - 1
+		for i := 0; i < per && len(fatSectors) < numFAT; i++ {
+			fatSectors = append(fatSectors, binary.LittleEndian.Uint32(sector[4*i:]))
+		}
+		next = binary.LittleEndian.Uint32(sector[4*per:])
+	}
+	for _, s := range fatSectors {
+		sector, ok := c.sector(s)
+		if !ok {
+			return nil, errCFBCorrupt
+		}
+		for i := 0; i < c.sectorSize; i += 4 {
+			c.fat = append(c.fat, binary.LittleEndian.Uint32(sector[i:]))
+		}
+	}
+
+	dirData := c.readChain(uint32(peekLe(hdr[48:], 4)), maxCFBDirEntries*cfbDirEntrySize)
+	if len(dirData) < cfbDirEntrySize {
+		return nil, errCFBCorrupt
+	}
+	for off := 0; off+cfbDirEntrySize <= len(dirData); off += cfbDirEntrySize {
+		c.dir = append(c.dir, parseCFBEntry(dirData[off:off+cfbDirEntrySize], c.sectorSize == 512))
+	}
+	if c.dir[0].typ != cfbTypeRoot {
+		return nil, errCFBCorrupt
+	}
+
+	miniFAT := c.readChain(uint32(peekLe(hdr[60:], 4)), 64<<20)
+	for i := 0; i+4 <= len(miniFAT); i += 4 {
+		c.miniFAT = append(c.miniFAT, binary.LittleEndian.Uint32(miniFAT[i:]))
+	}
+	c.miniChain = c.chain(c.dir[0].start)
+	return c, nil
+}
+
+func parseCFBEntry(e []byte, v3 bool) cfbEntry {
+	nameLen := min(peekLe(e[64:], 2), 64)
+	units := make([]uint16, 0, nameLen/2)
+	for i := 0; i+1 < nameLen; i += 2 {
+		if u := binary.LittleEndian.Uint16(e[i:]); u != 0 {
+			units = append(units, u)
+		}
+	}
+	entry := cfbEntry{
+		name:  string(utf16.Decode(units)),
+		typ:   e[66],
+		left:  binary.LittleEndian.Uint32(e[68:]),
+		right: binary.LittleEndian.Uint32(e[72:]),
+		child: binary.LittleEndian.Uint32(e[76:]),
+		start: binary.LittleEndian.Uint32(e[116:]),
+		size:  binary.LittleEndian.Uint64(e[120:]),
+	}
+	copy(entry.clsid[:], e[80:96])
+	if v3 {
+		// Version 3 files may leave garbage in the high half.
+		entry.size &= 0xFFFFFFFF
+	}
+	return entry
+}
+
+// sectors is the number of sectors the input can hold.
+func (c *cfbFile) sectors() int {
+	size := int64(len(c.b))
+	if c.file != nil {
+		size = c.file.size
+	}
+	return int(size / int64(c.sectorSize))
+}
+
+func (c *cfbFile) sector(n uint32) ([]byte, bool) {
+	if int64(n) >= int64(c.sectors()) {
+		return nil, false
+	}
+	return readAt(c.b, c.file, (int(n)+1)*c.sectorSize, c.sectorSize)
+}
+
+// chain follows the FAT from start, stopping at the end of chain, a bad
+// sector number or a loop.
+func (c *cfbFile) chain(start uint32) []uint32 {
+	var out []uint32
+	for s := start; s <= cfbMaxSector && int(s) < len(c.fat) && len(out) <= len(c.fat); s = c.fat[s] {
+		out = append(out, s)
+	}
+	return out
+}
+
+// readChain returns up to max bytes of the sector chain from start.
+func (c *cfbFile) readChain(start uint32, max int) []byte {
+	var out []byte
+	for _, s := range c.chain(start) {
+		if len(out) >= max {
+			break
+		}
+		sector, ok := c.sector(s)
+		if !ok {
+			break
+		}
+		out = append(out, sector...)
+	}
+	return out
+}
+
+// readStream returns up to max bytes of a stream entry.
+func (c *cfbFile) readStream(e *cfbEntry, max int) []byte {
+	if e == nil {
+		return nil
+	}
+	size := int(min(e.size, uint64(max)))
+	if e.size >= c.miniCutoff {
+		data := c.readChain(e.start, size)
+		return data[:min(size, len(data))]
+	}
+
+	// Small streams live in 64-byte sectors of the root entry's stream.
+	var out []byte
+	for s := e.start; s <= cfbMaxSector && int(s) < len(c.miniFAT) && len(out) < size; s = c.miniFAT[s] {
+		off := int(s) * 64
+		idx := off / c.sectorSize
+		if idx >= len(c.miniChain) || len(out) > len(c.miniFAT)*64 {
+			break
+		}
+		sector, ok := c.sector(c.miniChain[idx])
+		if !ok {
+			break
+		}
+		out = append(out, sector[off%c.sectorSize:off%c.sectorSize+64]...)
+	}
+	return out[:min(size, len(out))]
+}
+
+// children lists the entries of storage i, walking its red-black tree.
+func (c *cfbFile) children(i int) []*cfbEntry {
+	var out []*cfbEntry
+	var walk func(id uint32, depth int)
+	walk = func(id uint32, depth int) {
+		if id >= uint32(len(c.dir)) || depth > 64 || len(out) >= len(c.dir) {
+			return
+		}
+		e := &c.dir[id]
+		walk(e.left, depth+1)
+		out = append(out, e)
+		walk(e.right, depth+1)
+	}
+	walk(c.dir[i].child, 0)
+	return out
+}
+
+// child finds a direct child of storage i by case-insensitive name.
+func (c *cfbFile) child(i int, name string) *cfbEntry {
+	for _, e := range c.children(i) {
+		if strings.EqualFold(e.name, name) {
+			return e
+		}
+	}
+	return nil
+}
+
+// cfbType is a compound file application, with the description and MIME
+// type describeCFB reports for it.
+type cfbType struct {
+	desc string
+	mime string
+}
+
+var (
+	cfbWord       = cfbType{"Microsoft Word 97-2003 document", "application/msword"}
+	cfbExcel      = cfbType{"Microsoft Excel 97-2003 workbook", "application/vnd.ms-excel"}
+	cfbExcel95    = cfbType{"Microsoft Excel 5.0/95 workbook", "application/vnd.ms-excel"}
+	cfbPowerPoint = cfbType{"Microsoft PowerPoint 97-2003 presentation", "application/vnd.ms-powerpoint"}
+	cfbVisio      = cfbType{"Microsoft Visio 2003-2010 drawing", "application/vnd.visio"}
+	cfbPublisher  = cfbType{"Microsoft Publisher document", "application/x-mspublisher"}
+	cfbMsi        = cfbType{"Microsoft Installer (MSI)", "application/x-msi"}
+	cfbMsp        = cfbType{"Microsoft Installer patch (MSP)", "application/x-ms-patch"}
+	cfbMst        = cfbType{"Microsoft Installer transform (MST)", "application/x-ms-transform"}
+	cfbMsg        = cfbType{"Microsoft Outlook MSG message", "application/vnd.ms-outlook"}
+	cfbThumbs     = cfbType{"Windows thumbnail cache (Thumbs.db)", "application/x-ole-storage"}
+	cfbEncrypted  = cfbType{"Microsoft Office 2007+ encrypted document", "application/x-ole-storage"}
+	cfbUnknown    = cfbType{"Microsoft Office (Legacy format)", "application/x-ole-storage"}
+)
+
+var cfbTypes = []cfbType{cfbWord, cfbExcel, cfbExcel95, cfbPowerPoint, cfbVisio, cfbPublisher,
+	cfbMsi, cfbMsp, cfbMst, cfbMsg, cfbThumbs, cfbEncrypted, cfbUnknown}
+
+// Root storage CLSIDs, in their on-disk (mixed-endian) byte order.
+var cfbClassIDs = map[string]cfbType{
+	"\x84\x10\x0C\x00\x00\x00\x00\x00\xC0\x00\x00\x00\x00\x00\x00\x46": cfbMsi,
+	"\x86\x10\x0C\x00\x00\x00\x00\x00\xC0\x00\x00\x00\x00\x00\x00\x46": cfbMsp,
+	"\x82\x10\x0C\x00\x00\x00\x00\x00\xC0\x00\x00\x00\x00\x00\x00\x46": cfbMst,
+	"\x01\x12\x02\x00\x00\x00\x00\x00\xC0\x00\x00\x00\x00\x00\x00\x46": cfbPublisher,
+}
+
+// cfbMIME returns the MIME type of the longest cfbTypes description that
+// starts dl, a lower-cased description.
+func cfbMIME(dl string) string {
+	best, mime := 0, ""
+	for _, t := range cfbTypes {
+		if len(t.desc) > best && strings.HasPrefix(dl, strings.ToLower(t.desc)) {
+			best, mime = len(t.desc), t.mime
+		}
+	}
+	return mime
+}
+
+// describeCFB names the application behind a compound file from its root
+// CLSID and top-level streams, and reports VBA macros, encryption and the
+// SummaryInformation title and author. Inputs whose directory cannot be
+// read fall back to sniffing the leading bytes.
+func describeCFB(b []byte, file *source) (string, map[string]any) {
+	c, err := openCFB(b, file)
+	if err != nil {
+		switch {
+		case looksLikeMsi(b):
+			return cfbMsi.desc, nil
+		case looksLikeMsg(b):
+			return cfbMsg.desc, nil
+		}
+		return cfbUnknown.desc, nil
+	}
+
+	kind := cfbUnknown
+	has := func(name string) bool { return c.child(0, name) != nil }
+	if t, ok := cfbClassIDs[string(c.dir[0].clsid[:])]; ok {
+		kind = t
+	} else {
+		switch {
+		case has("EncryptionInfo") && has("EncryptedPackage"):
+			kind = cfbEncrypted
+		case has("WordDocument"):
+			kind = cfbWord
+		case has("Workbook"):
+			kind = cfbExcel
+		case has("Book"):
+			kind = cfbExcel95
+		case has("PowerPoint Document"):
+			kind = cfbPowerPoint
+		case has("VisioDocument"):
+			kind = cfbVisio
+		case has("Quill"):
+			kind = cfbPublisher
+		case has("__properties_version1.0"):
+			kind = cfbMsg
+		case has("Catalog"):
+			kind = cfbThumbs
+		}
+	}
+
+	desc := kind.desc
+	fields := map[string]any{}
+	if kind == cfbEncrypted || c.legacyEncrypted(kind) {
+		if kind != cfbEncrypted {
+			desc += ", encrypted"
+		}
+		fields["encrypted"] = true
+	}
+	if c.hasVBA() {
+		desc += ", with VBA macros"
+		fields["vba_macros"] = true
+	}
+	if si := c.child(0, "\x05SummaryInformation"); si != nil && si.typ == cfbTypeStream {
+		props := parsePropertySet(c.readStream(si, 256<<10))
+		if v := props[2]; v != "" {
+			desc += ", Title: " + v
+		}
+		if v := props[4]; v != "" {
+			desc += ", Author: " + v
+		}
+		for id, key := range summaryInformationFields {
+			if v := props[id]; v != "" {
+				fields[key] = v
+			}
+		}
+	}
+	if len(fields) == 0 {
+		fields = nil
+	}
+	return desc, fields
+}
+
+// summaryInformationFields are the SummaryInformation properties reported
+// in Result.Fields.
+var summaryInformationFields = map[uint32]string{
+	2:  "title",
+	3:  "subject",
+	4:  "author",
+	5:  "keywords",
+	8:  "last_author",
+	12: "created",
+	13: "modified",
+	18: "application",
+}
+
+// hasVBA looks for the storages Office keeps VBA projects in: Macros (Word),
+// _VBA_PROJECT_CUR (Excel, Visio) or a VBA storage with a dir stream.
+func (c *cfbFile) hasVBA() bool {
+	for i, e := range c.dir {
+		if e.typ != cfbTypeStorage {
+			continue
+		}
+		switch strings.ToUpper(e.name) {
+		case "MACROS", "_VBA_PROJECT_CUR":
+			return true
+		case "VBA":
+			if c.child(i, "dir") != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// legacyEncrypted checks the pre-2007 encryption flags: fEncrypted in a Word
+// FIB, a FILEPASS record in an Excel BIFF stream, or PowerPoint's
+// EncryptedSummary stream.
+func (c *cfbFile) legacyEncrypted(kind cfbType) bool {
+	switch kind {
+	case cfbWord:
+		fib := c.readStream(c.child(0, "WordDocument"), 32)
+		return len(fib) >= 12 && fib[11]&0x01 != 0
+	case cfbExcel, cfbExcel95:
+		name := "Workbook"
+		if kind == cfbExcel95 {
+			name = "Book"
+		}
+		biff := c.readStream(c.child(0, name), 4096)
+		// FILEPASS follows BOF among the first records of the globals.
+		for off, i := 0, 0; off+4 <= len(biff) && i < 32; i++ {
+			typ, size := peekLe(biff[off:], 2), peekLe(biff[off+2:], 2)
+			if typ == 0x002F {
+				return true
+			}
+			off += 4 + size
+		}
+	case cfbPowerPoint:
+		return c.child(0, "EncryptedSummary") != nil
+	}
+	return false
+}
+
+// parsePropertySet decodes the string and FILETIME properties of the first
+// section of an OLE property set stream, keyed by property ID.
+func parsePropertySet(data []byte) map[uint32]string {
+	if len(data) < 48 || peekLe(data, 2) != 0xFFFE {
+		return nil
+	}
+	section := peekLe(data[44:], 4)
+	if section < 48 || section+8 > len(data) {
+		return nil
+	}
+	s := data[section:]
+	count := min(peekLe(s[4:], 4), (len(s)-8)/8)
+
+	codepage := 0
+	props := map[uint32]string{}
+	for i := 0; i < count; i++ {
+		id := uint32(peekLe(s[8+8*i:], 4))
+		off := peekLe(s[12+8*i:], 4)
+		if off+8 > len(s) {
+			continue
+		}
+		v := s[off:]
+		switch peekLe(v, 4) {
+		case 0x02: // VT_I2, only used for the codepage
+			if id == 1 {
+				codepage = peekLe(v[4:], 2)
+			}
+		case 0x1E: // VT_LPSTR
+			n := min(peekLe(v[4:], 4), len(v)-8)
+			props[id] = decodeCodepage(bytes.TrimRight(v[8:8+n], "\x00"), codepage)
+		case 0x1F: // VT_LPWSTR, a count of UTF-16 units
+			n := min(peekLe(v[4:], 4), (len(v)-8)/2)
+			units := make([]uint16, 0, n)
+			for j := 0; j < n; j++ {
+				if u := binary.LittleEndian.Uint16(v[8+2*j:]); u != 0 {
+					units = append(units, u)
+				}
+			}
+			props[id] = string(utf16.Decode(units))
+		case 0x40: // VT_FILETIME
+			if off+12 > len(s) {
+				continue
+			}
+			ft := binary.LittleEndian.Uint64(v[4:])
+			if t, ok := fileTime(ft); ok {
+				props[id] = t.Format(time.RFC3339)
+			}
+		}
+	}
+	for id, v := range props {
+		if v = strings.TrimSpace(v); v == "" {
+			delete(props, id)
+		} else {
+			props[id] = v
+		}
+	}
+	return props
+}
+
+// decodeCodepage converts an 8-bit property string. UTF-8 is kept; other
+// code pages are read as Latin-1, which is right for ASCII and close enough
+// for Windows-1252.
+func decodeCodepage(b []byte, codepage int) string {
+	if codepage == 65001 {
+		return string(b)
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// fileTime converts a Windows FILETIME (100ns ticks since 1601), rejecting
+// zero and implausible values.
+func fileTime(ft uint64) (time.Time, bool) {
+	const epochDelta = 116444736000000000 // 1601-01-01 to 1970-01-01
+	if ft <= epochDelta || ft > epochDelta+uint64(200*365*24*time.Hour/100) {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(ft-epochDelta)*100).UTC(), true
+}
+
+func looksLikeMsg(b []byte) bool {
+	hasProps := sampleContainsASCIIOrUTF16LE(b, "__properties_version1.0", 256*1024)
+	hasSubst := sampleContainsASCIIOrUTF16LE(b, "__substg1.0_", 256*1024)
+	hasNameID := sampleContainsASCIIOrUTF16LE(b, "__nameid_version1.0", 256*1024)
+	return hasProps && (hasSubst || hasNameID)
+}
@@ -0,0 +1,415 @@
+package magic
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// maxFooterRead bounds the Parquet and Arrow footers read for a summary.
+const maxFooterRead = 16 << 20
+
+// thriftCompact reads the Thrift compact protocol Parquet footers use. Any
+// malformed or truncated input sets err, after which every read returns
+// zero values.
+type thriftCompact struct {
+	b     []byte
+	pos   int
+	depth int
+	err   bool
+}
+
+func (t *thriftCompact) byte() byte {
+	if t.err || t.pos >= len(t.b) {
+		t.err = true
+		return 0
+	}
+	t.pos++
+	return t.b[t.pos-1]
+}
+
+func (t *thriftCompact) varint() uint64 {
+	var v uint64
+	for shift := 0; shift < 64; shift += 7 {
+		c := t.byte()
+		v |= uint64(c&0x7F) << shift
+		if c&0x80 == 0 {
+			return v
+		}
+	}
+	t.err = true
+	return 0
+}
+
+func (t *thriftCompact) zigzag() int64 {
+	v := t.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (t *thriftCompact) binary() []byte {
+	n := t.varint()
+	if t.err || n > uint64(len(t.b)-t.pos) {
+		t.err = true
+		return nil
+	}
+	t.pos += int(n)
+	return t.b[t.pos-int(n) : t.pos]
+}
+
+// list reads a list or set header: element type and count.
+func (t *thriftCompact) list() (byte, int) {
+	h := t.byte()
+	n := uint64(h >> 4)
+	if n == 15 {
+		n = t.varint()
+	}
+	if n > uint64(len(t.b)) {
+		t.err = true
+		return 0, 0
+	}
+	return h & 0x0F, int(n)
+}
+
+// fields calls fn with the id and type of each field of a struct; fn must
+// consume the value, with skip if it has no use for it.
+func (t *thriftCompact) fields(fn func(id int, typ byte)) {
+	if t.depth++; t.depth > 64 {
+		t.err = true
+	}
+	defer func() { t.depth-- }()
+	id := 0
+	for !t.err {
+		h := t.byte()
+		typ := h & 0x0F
+		if typ == 0 {
+			return
+		}
+		if delta := int(h >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int(int16(t.zigzag()))
+		}
+		fn(id, typ)
+	}
+}
+
+// skip consumes a value of typ. Booleans are held in the field header of a
+// struct, so only list elements (inList) take a byte.
+func (t *thriftCompact) skip(typ byte, inList bool) {
+	if typ >= 9 && typ <= 12 {
+		// Containers nest through skip as well as fields, so both count
+		// towards the depth limit.
+		if t.depth++; t.depth > 64 {
+			t.err = true
+		}
+		defer func() { t.depth-- }()
+		if t.err {
+			return
+		}
+	}
+	switch typ {
+	case 1, 2:
+		if inList {
+			t.byte()
+		}
+	case 3:
+		t.byte()
+	case 4, 5, 6:
+		t.varint()
+	case 7:
+		if t.pos += 8; t.pos > len(t.b) {
+			t.err = true
+		}
+	case 8:
+		t.binary()
+	case 9, 10:
+		et, n := t.list()
+		for i := 0; i < n && !t.err; i++ {
+			t.skip(et, true)
+		}
+	case 11:
+		n := t.varint()
+		if n == 0 {
+			return
+		}
+		kv := t.byte()
+		for i := uint64(0); i < n && !t.err; i++ {
+			t.skip(kv>>4, true)
+			t.skip(kv&0x0F, true)
+		}
+	case 12:
+		t.fields(func(_ int, typ byte) { t.skip(typ, false) })
+	default:
+		t.err = true
+	}
+}
+
+// parquetSummary reads row count, leaf column count and created_by from the
+// FileMetaData footer: "<metadata> <length u32le> PAR1".
+func parquetSummary(_ []byte, file *source) (string, map[string]any) {
+	tail, ok := readTail(file, 8)
+	if !ok {
+		return "", nil
+	}
+	n := int64(binary.LittleEndian.Uint32(tail))
+	if n == 0 || n > maxFooterRead || n > file.size-12 {
+		return "", nil
+	}
+	meta, ok := readRegion(nil, file, file.size-8-n, int(n))
+	if !ok {
+		return "", nil
+	}
+
+	t := &thriftCompact{b: meta}
+	rows, columns, createdBy := int64(-1), 0, ""
+	t.fields(func(id int, typ byte) {
+		switch {
+		case id == 2 && typ == 9:
+			// The schema flattens the tree depth-first; its first element
+			// is the root and leaves are the ones without num_children.
+			et, count := t.list()
+			for i := 0; i < count && !t.err; i++ {
+				if et != 12 {
+					t.skip(et, true)
+					continue
+				}
+				children := int64(0)
+				t.fields(func(id int, typ byte) {
+					if id == 5 && typ == 5 {
+						children = t.zigzag()
+					} else {
+						t.skip(typ, false)
+					}
+				})
+				if i > 0 && children == 0 {
+					columns++
+				}
+			}
+		case id == 3 && typ == 6:
+			rows = t.zigzag()
+		case id == 6 && typ == 8:
+			createdBy = string(t.binary())
+		default:
+			t.skip(typ, false)
+		}
+	})
+	if t.err {
+		return "", nil
+	}
+
+	var out strings.Builder
+	f := map[string]any{}
+	if rows >= 0 {
+		out.WriteString(", " + plural(rows, "row"))
+		f["rows"] = rows
+	}
+	out.WriteString(", " + plural(int64(columns), "column"))
+	f["columns"] = columns
+	if createdBy != "" && isPrintableASCII(createdBy) {
+		out.WriteString(", created by " + createdBy)
+		f["created_by"] = createdBy
+	}
+	return out.String(), f
+}
+
+// avroSummary reads the writer schema's name and field count and the codec
+// from the header metadata map that follows "Obj\x01".
+func avroSummary(b []byte, file *source) (string, map[string]any) {
+	data := b
+	if file != nil && file.size > int64(len(b)) {
+		if d, ok := readAt(b, file, 0, int(min(file.size, 1<<20))); ok {
+			data = d
+		}
+	}
+	if len(data) < 4 {
+		return "", nil
+	}
+
+	// Avro longs are zigzag varints like Thrift compact i64s, and bytes are
+	// prefixed with their length as a long. The map is written in blocks of
+	// counts; a negative count is followed by the block's size in bytes,
+	// and a zero count ends it.
+	t := &thriftCompact{b: data, pos: 4}
+	avroBytes := func() []byte {
+		n := t.zigzag()
+		if t.err || n < 0 || n > int64(len(t.b)-t.pos) {
+			t.err = true
+			return nil
+		}
+		t.pos += int(n)
+		return t.b[t.pos-int(n) : t.pos]
+	}
+	meta := map[string][]byte{}
+	for !t.err {
+		n := t.zigzag()
+		if n == 0 {
+			break
+		}
+		if n < 0 {
+			n = -n
+			t.zigzag()
+		}
+		for i := int64(0); i < n && !t.err; i++ {
+			key := string(avroBytes())
+			meta[key] = avroBytes()
+		}
+	}
+	if t.err {
+		return "", nil
+	}
+
+	var out strings.Builder
+	f := map[string]any{}
+	if name, fields, ok := avroSchema(meta["avro.schema"]); ok {
+		out.WriteString(", schema " + name)
+		f["schema"] = name
+		if fields >= 0 {
+			out.WriteString(" (" + plural(int64(fields), "field") + ")")
+			f["schema_fields"] = fields
+		}
+	}
+	// A file without avro.codec is uncompressed.
+	codec := "null"
+	if c, ok := meta["avro.codec"]; ok {
+		codec = string(c)
+	}
+	if isPrintableASCII(codec) && codec != "" {
+		out.WriteString(", codec " + codec)
+		f["codec"] = codec
+	}
+	return out.String(), f
+}
+
+// avroSchema names a schema: the full name of a named type, or the type
+// itself for primitives, unions and the like. fields is -1 unless it is a
+// record.
+func avroSchema(text []byte) (string, int, bool) {
+	var schema any
+	if len(text) == 0 || json.Unmarshal(text, &schema) != nil {
+		return "", -1, false
+	}
+	switch s := schema.(type) {
+	case string:
+		return s, -1, true
+	case []any:
+		return "union", -1, true
+	case map[string]any:
+		name, _ := s["name"].(string)
+		if ns, _ := s["namespace"].(string); ns != "" && name != "" && !strings.Contains(name, ".") {
+			name = ns + "." + name
+		}
+		if name == "" {
+			name, _ = s["type"].(string)
+		}
+		if name == "" {
+			return "", -1, false
+		}
+		if fields, ok := s["fields"].([]any); ok {
+			return name, len(fields), true
+		}
+		return name, -1, true
+	}
+	return "", -1, false
+}
+
+// flatTable is a FlatBuffers table, as Arrow's footer is built from.
+type flatTable struct {
+	b   []byte
+	pos int
+}
+
+func flatRoot(b []byte) (flatTable, bool) {
+	if len(b) < 4 {
+		return flatTable{}, false
+	}
+	return flatTable{b, 0}.deref(0)
+}
+
+// deref follows the uoffset at pos+off to the table it points at.
+func (t flatTable) deref(off int) (flatTable, bool) {
+	at := t.pos + off
+	if at < 0 || at+4 > len(t.b) {
+		return flatTable{}, false
+	}
+	pos := at + int(binary.LittleEndian.Uint32(t.b[at:]))
+	if pos+4 > len(t.b) || pos < 0 {
+		return flatTable{}, false
+	}
+	return flatTable{t.b, pos}, true
+}
+
+// field returns the offset within the table of field i, or 0 when absent.
+func (t flatTable) field(i int) int {
+	vt := t.pos - int(int32(binary.LittleEndian.Uint32(t.b[t.pos:])))
+	if vt < 0 || vt+4 > len(t.b) {
+		return 0
+	}
+	size := int(binary.LittleEndian.Uint16(t.b[vt:]))
+	at := vt + 4 + 2*i
+	if 4+2*i+2 > size || at+2 > len(t.b) {
+		return 0
+	}
+	return int(binary.LittleEndian.Uint16(t.b[at:]))
+}
+
+// table follows field i to a sub-table or vector.
+func (t flatTable) table(i int) (flatTable, bool) {
+	off := t.field(i)
+	if off == 0 {
+		return flatTable{}, false
+	}
+	return t.deref(off)
+}
+
+// arrowSummary counts the schema fields in the footer of an Arrow IPC file
+// (Feather V2): "<Footer flatbuffer> <length i32le> ARROW1".
+func arrowSummary(_ []byte, file *source) (string, map[string]any) {
+	tail, ok := readTail(file, 10)
+	if !ok {
+		return "", nil
+	}
+	n := int64(binary.LittleEndian.Uint32(tail))
+	if n < 4 || n > maxFooterRead || n > file.size-18 {
+		return "", nil
+	}
+	footer, ok := readRegion(nil, file, file.size-10-n, int(n))
+	if !ok {
+		return "", nil
+	}
+	// Footer.schema is field 1, Schema.fields field 1.
+	root, ok := flatRoot(footer)
+	if !ok {
+		return "", nil
+	}
+	schema, ok := root.table(1)
+	if !ok {
+		return "", nil
+	}
+	fields, ok := schema.table(1)
+	if !ok {
+		return "", nil
+	}
+	count := int(binary.LittleEndian.Uint32(footer[fields.pos:]))
+	if count > len(footer) {
+		return "", nil
+	}
+	return ", " + plural(int64(count), "schema field"), map[string]any{"schema_fields": count}
+}
+
+// plural formats "1 row", "2 rows".
+func plural(n int64, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// hdf5Summary reports the superblock version, the byte after the signature.
+func hdf5Summary(b []byte, _ *source) (string, map[string]any) {
+	if len(b) < 9 {
+		return "", nil
+	}
+	v := int(b[8])
+	return fmt.Sprintf(", superblock version %d", v), map[string]any{"superblock_version": v}
+}
@@ -0,0 +1,87 @@
+package magic
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+// maxDecompressDepth bounds how many compression layers Options.Decompress
+// looks through, so a stream nested on itself cannot keep us busy.
+const maxDecompressDepth = 4
+
+// decompressors open a decoding reader for each compression matcher.
+var decompressors = map[string]func(io.Reader) (io.Reader, error){
+	"gzip": func(r io.Reader) (io.Reader, error) {
+		return gzip.NewReader(r)
+	},
+	"bzip2": func(r io.Reader) (io.Reader, error) {
+		return bzip2.NewReader(r), nil
+	},
+	"xz": func(r io.Reader) (io.Reader, error) {
+		return xz.NewReader(r)
+	},
+	"zstd": func(r io.Reader) (io.Reader, error) {
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(64<<20))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	},
+	"lz4": func(r io.Reader) (io.Reader, error) {
+		return lz4.NewReader(r), nil
+	},
+	"zlib": func(r io.Reader) (io.Reader, error) {
+		return zlib.NewReader(r)
+	},
+}
+
+// decompressedResult looks inside res when it names a compressed stream,
+// like file -z: the payload's leading MaxBytesToRead bytes are classified
+// and the description becomes "payload (compression)".
+func decompressedResult(res Result, contentByte []byte, opts Options, file *source) Result {
+	open, ok := decompressors[res.Matcher]
+	if !ok || opts.depth >= maxDecompressDepth {
+		return res
+	}
+
+	var in io.Reader = bytes.NewReader(contentByte)
+	if file != nil {
+		in = file.reader()
+	}
+	r, err := open(in)
+	if err != nil {
+		return res
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+	payload := make([]byte, MaxBytesToRead)
+	n, err := io.ReadFull(r, payload)
+	if n == 0 && err != io.EOF {
+		// Not actually a stream of this kind (zlib's two-byte check is weak).
+		return res
+	}
+
+	inner := opts
+	inner.depth++
+	inner.KeepGoing = false
+	got, err := detectFromBytes(payload[:n], inner, nil)
+	if err != nil {
+		return res
+	}
+	desc := got.Description + " (" + res.Description + ")"
+	res.Description = desc
+	res.MIME = got.MIME
+	if len(res.Candidates) > 0 {
+		res.Candidates[0].Description = desc
+		res.Candidates[0].MIME = got.MIME
+	}
+	return res
+}
@@ -0,0 +1,323 @@
+package magic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// tiffReader reads TIFF image file directories, from a whole TIFF file
+// (base 0 in file) or from a TIFF structure embedded in something else, such
+// as a JPEG APP1 segment (b alone, file nil).
+type tiffReader struct {
+	b     []byte
+	file  *source
+	base  int64
+	order binary.ByteOrder
+	first int64 // offset of IFD0
+}
+
+// tiffEntry is one directory entry; value holds the raw value bytes.
+type tiffEntry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+const (
+	tiffMaxEntries = 1024
+	tiffMaxValue   = 64 << 10
+)
+
+var tiffTypeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8, 13: 4}
+
+// newTiffReader checks the byte-order mark at base and reads the offset of
+// IFD0. The two bytes after the mark are not checked, so the Olympus ("RO")
+// and Panasonic ("U") variants read the same way.
+func newTiffReader(b []byte, file *source, base int64) *tiffReader {
+	hdr, ok := readRegion(b, file, base, 8)
+	if !ok {
+		return nil
+	}
+	t := &tiffReader{b: b, file: file, base: base}
+	switch {
+	case hasPrefix(hdr, "II"):
+		t.order = binary.LittleEndian
+	case hasPrefix(hdr, "MM"):
+		t.order = binary.BigEndian
+	default:
+		return nil
+	}
+	t.first = int64(t.order.Uint32(hdr[4:]))
+	return t
+}
+
+// ifd reads the directory at off, returning its entries by tag and the
+// offset of the next directory (0 at the end of the chain).
+func (t *tiffReader) ifd(off int64) (map[uint16]tiffEntry, int64) {
+	if off < 8 {
+		return nil, 0
+	}
+	hdr, ok := readRegion(t.b, t.file, t.base+off, 2)
+	if !ok {
+		return nil, 0
+	}
+	n := int(t.order.Uint16(hdr))
+	if n == 0 || n > tiffMaxEntries {
+		return nil, 0
+	}
+	dir, ok := readRegion(t.b, t.file, t.base+off+2, n*12+4)
+	if !ok {
+		return nil, 0
+	}
+	entries := make(map[uint16]tiffEntry, n)
+	for i := 0; i < n; i++ {
+		e := dir[i*12:]
+		tag := t.order.Uint16(e)
+		entry := tiffEntry{typ: t.order.Uint16(e[2:]), count: t.order.Uint32(e[4:])}
+		size := tiffTypeSizes[entry.typ] * int(entry.count)
+		if size == 0 || size > tiffMaxValue {
+			continue
+		}
+		if size <= 4 {
+			entry.value = e[8 : 8+size]
+		} else if v, ok := readRegion(t.b, t.file, t.base+int64(t.order.Uint32(e[8:])), size); ok {
+			entry.value = v
+		} else {
+			continue
+		}
+		entries[tag] = entry
+	}
+	return entries, int64(t.order.Uint32(dir[n*12:]))
+}
+
+// uint returns the i'th value of a BYTE, SHORT or LONG entry.
+func (t *tiffReader) uint(e tiffEntry, i int) (int, bool) {
+	if i < 0 || i >= int(e.count) {
+		return 0, false
+	}
+	switch e.typ {
+	case 1:
+		return int(e.value[i]), true
+	case 3:
+		return int(t.order.Uint16(e.value[2*i:])), true
+	case 4:
+		return int(t.order.Uint32(e.value[4*i:])), true
+	}
+	return 0, false
+}
+
+// tag is uint for the first value of tag in entries.
+func (t *tiffReader) tag(entries map[uint16]tiffEntry, tag uint16) (int, bool) {
+	e, ok := entries[tag]
+	if !ok {
+		return 0, false
+	}
+	return t.uint(e, 0)
+}
+
+// str returns an ASCII entry without its terminator and padding.
+func (t *tiffReader) str(entries map[uint16]tiffEntry, tag uint16) string {
+	e, ok := entries[tag]
+	if !ok || e.typ != 2 {
+		return ""
+	}
+	if i := bytes.IndexByte(e.value, 0); i >= 0 {
+		e.value = e.value[:i]
+	}
+	s := strings.TrimSpace(string(e.value))
+	if !isPrintableASCII(s) {
+		return ""
+	}
+	return s
+}
+
+// exifInfo is what --exif reports about the camera and capture.
+type exifInfo struct {
+	make        string
+	model       string
+	orientation int
+	datetime    string
+}
+
+var exifOrientations = []string{
+	1: "upper-left", 2: "upper-right", 3: "lower-right", 4: "lower-left",
+	5: "left-top", 6: "right-top", 7: "right-bottom", 8: "left-bottom",
+}
+
+// decodeExif reads camera make and model, orientation and capture time
+// from IFD0 and the Exif sub-IFD. It returns nil when none are present.
+func decodeExif(t *tiffReader) *exifInfo {
+	if t == nil {
+		return nil
+	}
+	ifd0, _ := t.ifd(t.first)
+	if ifd0 == nil {
+		return nil
+	}
+	info := &exifInfo{
+		make:     t.str(ifd0, 0x010F),
+		model:    t.str(ifd0, 0x0110),
+		datetime: t.str(ifd0, 0x0132),
+	}
+	if o, ok := t.tag(ifd0, 0x0112); ok && o >= 1 && o <= 8 {
+		info.orientation = o
+	}
+	// DateTimeOriginal is the capture time; DateTime is the last edit.
+	sub := ifd0
+	if off, ok := t.tag(ifd0, 0x8769); ok {
+		sub, _ = t.ifd(int64(off))
+	}
+	if dt := t.str(sub, 0x9003); dt != "" {
+		info.datetime = dt
+	}
+	if *info == (exifInfo{}) {
+		return nil
+	}
+	return info
+}
+
+// merge fills in whatever info lacks from other, for formats that split
+// Exif over several blocks (Canon CR3).
+func (info *exifInfo) merge(other *exifInfo) *exifInfo {
+	if info == nil {
+		return other
+	}
+	if other == nil {
+		return info
+	}
+	merged := *info
+	if merged.make == "" {
+		merged.make = other.make
+	}
+	if merged.model == "" {
+		merged.model = other.model
+	}
+	if merged.orientation == 0 {
+		merged.orientation = other.orientation
+	}
+	if merged.datetime == "" {
+		merged.datetime = other.datetime
+	}
+	return &merged
+}
+
+func (info *exifInfo) String() string {
+	var parts []string
+	if info.make != "" {
+		parts = append(parts, "manufacturer="+info.make)
+	}
+	if info.model != "" {
+		parts = append(parts, "model="+info.model)
+	}
+	if info.orientation != 0 {
+		parts = append(parts, "orientation="+exifOrientations[info.orientation])
+	}
+	if info.datetime != "" {
+		parts = append(parts, "datetime="+info.datetime)
+	}
+	return fmt.Sprintf(", Exif: [%s]", strings.Join(parts, ", "))
+}
+
+func (info *exifInfo) fields() map[string]any {
+	f := map[string]any{}
+	if info.make != "" {
+		f["make"] = info.make
+	}
+	if info.model != "" {
+		f["model"] = info.model
+	}
+	if info.orientation != 0 {
+		f["orientation"] = exifOrientations[info.orientation]
+	}
+	if info.datetime != "" {
+		f["datetime"] = info.datetime
+	}
+	return f
+}
+
+// exifReaders find the Exif block of the formats that carry one, keyed by
+// matcher name.
+var exifReaders = map[string]func([]byte, *source) *exifInfo{
+	"jpeg": jpegExif,
+	"tiff": tiffExif,
+	"cr2":  tiffExif,
+	"nef":  tiffExif,
+	"arw":  tiffExif,
+	"dng":  tiffExif,
+	"orf":  tiffExif,
+	"rw2":  tiffExif,
+	"heif": heifExif,
+	"avif": heifExif,
+	"cr3":  cr3Exif,
+}
+
+func tiffExif(b []byte, file *source) *exifInfo {
+	return decodeExif(newTiffReader(b, file, 0))
+}
+
+// jpegExif decodes the APP1 "Exif" segment.
+func jpegExif(b []byte, file *source) *exifInfo {
+	var info *exifInfo
+	jpegSegments(b, file, func(marker byte, off int64, length int) bool {
+		if marker != 0xE1 || length < 14 {
+			return !jpegSOF(marker) // Exif comes before the frame header
+		}
+		seg, ok := readRegion(b, file, off, length)
+		if !ok || !hasPrefix(seg, "Exif\x00\x00") {
+			return true
+		}
+		info = decodeExif(newTiffReader(seg[6:], nil, 0))
+		return false
+	})
+	return info
+}
+
+// heifExif decodes the "Exif" item of a HEIF or AVIF file. Its payload
+// starts with the offset of the TIFF header within it.
+func heifExif(b []byte, file *source) *exifInfo {
+	meta := bmffTopLevel(b, file, "meta")
+	if len(meta) < 4 {
+		return nil
+	}
+	id, ok := heifItemOfType(meta[4:], "Exif")
+	if !ok {
+		return nil
+	}
+	off, length, ok := heifItemLocation(meta[4:], id)
+	if !ok || length < 8 || length > 1<<20 {
+		return nil
+	}
+	item, ok := readRegion(b, file, off, int(length))
+	if !ok {
+		return nil
+	}
+	skip := 4 + int64(binary.BigEndian.Uint32(item))
+	if skip >= int64(len(item)) {
+		return nil
+	}
+	return decodeExif(newTiffReader(item[skip:], nil, 0))
+}
+
+// cr3Exif decodes the CMT1 (IFD0) and CMT2 (Exif IFD) boxes of Canon's
+// metadata uuid box, each of which is a complete TIFF structure.
+func cr3Exif(b []byte, file *source) *exifInfo {
+	moov := bmffTopLevel(b, file, "moov")
+	var info *exifInfo
+	bmffBoxes(moov, func(typ string, payload []byte) bool {
+		if typ != "uuid" || len(payload) < 16 || !bytes.Equal(payload[:16], cr3MetadataUUID) {
+			return true
+		}
+		bmffBoxes(payload[16:], func(typ string, payload []byte) bool {
+			if typ == "CMT1" || typ == "CMT2" {
+				info = info.merge(decodeExif(newTiffReader(payload, nil, 0)))
+			}
+			return true
+		})
+		return false
+	})
+	return info
+}
+
+var cr3MetadataUUID = []byte("\x85\xC0\xB6\x87\x82\x0F\x11\xE0\x81\x11\xF4\xCE\x46\x2B\x6A\x48")
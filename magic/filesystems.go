@@ -0,0 +1,227 @@
+package magic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// ext superblock feature flags reported (s_feature_compat and
+// s_feature_incompat).
+const (
+	extCompatHasJournal  = 0x0004
+	extIncompatRecover   = 0x0004
+	extIncompatExtents   = 0x0040
+	extIncompat64Bit     = 0x0080
+	extSuperblockOffset  = 1024
+	extSuperblockMinRead = extSuperblockOffset + 0x88
+)
+
+// describeExt names the ext generation from its features and, given the
+// whole superblock, reports UUID, volume name and the features GNU file
+// lists: "Linux ext4 filesystem, UUID=..., volume name "root" (needs
+// journal recovery) (journal) (extents) (64bit)".
+func describeExt(b []byte, _ *source) (string, map[string]any) {
+	if len(b) < 1124 {
+		return "Linux ext2 filesystem", nil
+	}
+	compat := peekLe(b[1116:], 4)
+	incompat := peekLe(b[1120:], 4)
+	var out strings.Builder
+	switch {
+	case incompat&(extIncompatExtents|extIncompat64Bit) != 0:
+		out.WriteString("Linux ext4 filesystem")
+	case compat&extCompatHasJournal != 0:
+		out.WriteString("Linux ext3 filesystem")
+	default:
+		out.WriteString("Linux ext2 filesystem")
+	}
+	if len(b) < extSuperblockMinRead {
+		return out.String(), nil
+	}
+
+	sb := b[extSuperblockOffset:]
+	f := map[string]any{}
+	if uuid := sb[0x68:0x78]; !bytes.Equal(uuid, make([]byte, 16)) {
+		s := fmt.Sprintf("%x-%x-%x-%x-%x", uuid[:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])
+		out.WriteString(", UUID=" + s)
+		f["uuid"] = s
+	}
+	if name := string(bytes.TrimRight(sb[0x78:0x88], "\x00")); name != "" && isPrintableASCII(name) {
+		fmt.Fprintf(&out, ", volume name %q", name)
+		f["label"] = name
+	}
+	if incompat&extIncompatRecover != 0 {
+		out.WriteString(" (needs journal recovery)")
+		f["needs_recovery"] = true
+	}
+	var features []string
+	for _, flag := range []struct {
+		set  bool
+		name string
+	}{
+		{compat&extCompatHasJournal != 0, "journal"},
+		{incompat&extIncompatExtents != 0, "extents"},
+		{incompat&extIncompat64Bit != 0, "64bit"},
+	} {
+		if flag.set {
+			out.WriteString(" (" + flag.name + ")")
+			features = append(features, flag.name)
+		}
+	}
+	if features != nil {
+		f["features"] = features
+	}
+	if len(f) == 0 {
+		f = nil
+	}
+	return out.String(), f
+}
+
+const (
+	isoSectorSize = 2048
+	// isoMaxDescriptors bounds the volume descriptor set walked for boot
+	// records and Joliet supplementary descriptors.
+	isoMaxDescriptors = 32
+)
+
+// describeISO9660 reports the volume identifier, El Torito boot record and
+// Joliet and Rock Ridge extensions of an ISO 9660 image:
+// "ISO 9660 CD-ROM filesystem 'UBUNTU' (bootable), Joliet, Rock Ridge".
+func describeISO9660(b []byte, file *source) (string, map[string]any) {
+	var out strings.Builder
+	out.WriteString("ISO 9660 CD-ROM filesystem")
+	f := map[string]any{}
+
+	var pvd []byte
+	bootable, joliet := false, false
+	for i := 0; i < isoMaxDescriptors; i++ {
+		vd, ok := readRegion(b, file, int64(16+i)*isoSectorSize, isoSectorSize)
+		if !ok || !equal(vd[1:6], "CD001") || vd[0] == 255 {
+			break
+		}
+		switch vd[0] {
+		case 0:
+			bootable = bootable || hasPrefix(vd[7:], "EL TORITO SPECIFICATION")
+		case 1:
+			if pvd == nil {
+				pvd = vd
+			}
+		case 2:
+			// Joliet is a supplementary descriptor whose escape sequences
+			// select UCS-2 level 1, 2 or 3.
+			esc := vd[88:91]
+			joliet = joliet || equal(esc, "%/@") || equal(esc, "%/C") || equal(esc, "%/E")
+		}
+	}
+	if pvd == nil {
+		return out.String(), nil
+	}
+
+	if id := strings.TrimRight(string(pvd[40:72]), " \x00"); id != "" && isPrintableASCII(id) {
+		out.WriteString(" '" + id + "'")
+		f["volume_id"] = id
+	}
+	if bootable {
+		out.WriteString(" (bootable)")
+		f["bootable"] = true
+	}
+	if joliet {
+		out.WriteString(", Joliet")
+		f["joliet"] = true
+	}
+	if isoRockRidge(b, file, pvd[156:190]) {
+		out.WriteString(", Rock Ridge")
+		f["rock_ridge"] = true
+	}
+	if len(f) == 0 {
+		f = nil
+	}
+	return out.String(), f
+}
+
+// isoRockRidge looks at the System Use area of the root directory's "."
+// record: Rock Ridge starts it with a SUSP "SP" entry, followed by Rock
+// Ridge entries or an "ER" entry naming RRIP.
+func isoRockRidge(b []byte, file *source, root []byte) bool {
+	extent := int64(binary.LittleEndian.Uint32(root[2:]))
+	dir, ok := readRegion(b, file, extent*isoSectorSize, isoSectorSize)
+	if !ok || dir[0] < 34 {
+		return false
+	}
+	rec := dir[:dir[0]]
+	// A pad byte keeps the System Use area at an even offset.
+	start := 33 + int(rec[32]) + 1 - int(rec[32])%2
+	if start > len(rec) {
+		return false
+	}
+	use := rec[start:]
+	if len(use) < 7 || !equal(use[:2], "SP") || use[4] != 0xBE || use[5] != 0xEF {
+		return false
+	}
+	for len(use) >= 4 {
+		n := int(use[2])
+		if n < 4 || n > len(use) {
+			break
+		}
+		switch string(use[:2]) {
+		case "RR", "PX", "NM", "SL", "TF":
+			return true
+		case "ER":
+			if bytes.Contains(use[:n], []byte("RRIP")) || bytes.Contains(use[:n], []byte("1282")) {
+				return true
+			}
+		}
+		use = use[n:]
+	}
+	// The ER entry may sit in a continuation area elsewhere.
+	return bytes.Contains(dir, []byte("RRIP_1991A")) || bytes.Contains(dir, []byte("IEEE_P1282")) || bytes.Contains(dir, []byte("IEEE_1282"))
+}
+
+var squashfsCompressors = map[int]string{1: "gzip", 2: "lzma", 3: "lzo", 4: "xz", 5: "lz4", 6: "zstd"}
+
+// describeSquashfs reports the byte order, version, compressor, inode count
+// and block size from a squashfs superblock: "Squashfs filesystem, little
+// endian, version 4.0, zstd compressed, 1234 inodes, block size 131072".
+func describeSquashfs(b []byte, _ *source) (string, map[string]any) {
+	const base = "Squashfs filesystem"
+	if len(b) < 96 {
+		return base, nil
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	endian := "little endian"
+	if hasPrefix(b, "sqsh") || hasPrefix(b, "sqlz") || hasPrefix(b, "qshs") {
+		order, endian = binary.BigEndian, "big endian"
+	}
+	major, minor := int(order.Uint16(b[28:])), int(order.Uint16(b[30:]))
+	inodes := int(order.Uint32(b[4:]))
+	var blockSize int
+	compressor := "gzip"
+	switch major {
+	case 4:
+		blockSize = int(order.Uint32(b[12:]))
+		compressor = squashfsCompressors[int(order.Uint16(b[20:]))]
+	case 3:
+		blockSize = int(order.Uint32(b[51:]))
+	case 1, 2:
+		blockSize = int(order.Uint16(b[32:]))
+	default:
+		return base, nil
+	}
+	// "sqlz" and "qshs" mark the LZMA patch set for 3.x.
+	if major < 4 && (hasPrefix(b, "sqlz") || hasPrefix(b, "qshs")) {
+		compressor = "lzma"
+	}
+
+	version := fmt.Sprintf("%d.%d", major, minor)
+	var out strings.Builder
+	fmt.Fprintf(&out, "%s, %s, version %s", base, endian, version)
+	f := map[string]any{"endian": strings.TrimSuffix(endian, " endian"), "version": version, "inodes": inodes, "block_size": blockSize}
+	if compressor != "" {
+		out.WriteString(", " + compressor + " compressed")
+		f["compression"] = compressor
+	}
+	fmt.Fprintf(&out, ", %s, block size %d", plural(int64(inodes), "inode"), blockSize)
+	return out.String(), f
+}
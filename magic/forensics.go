@@ -0,0 +1,359 @@
+package magic
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// artifact accumulates the description and fields of a forensic artifact.
+type artifact struct {
+	desc   strings.Builder
+	fields map[string]any
+}
+
+func newArtifact(base string) *artifact {
+	a := &artifact{fields: map[string]any{}}
+	a.desc.WriteString(base)
+	return a
+}
+
+// text adds ", label value" and the field key, skipping empty values.
+func (a *artifact) text(label, key, value string) {
+	if value == "" {
+		return
+	}
+	a.desc.WriteString(", " + label + value)
+	a.fields[key] = value
+}
+
+// time adds a FILETIME as ", label Mon Jan  2 15:04:05 2006" and as an
+// RFC 3339 field, skipping unset and implausible values.
+func (a *artifact) time(label, key string, ft uint64) {
+	t, ok := fileTime(ft)
+	if !ok {
+		return
+	}
+	a.desc.WriteString(", " + label + " " + t.Format("Mon Jan _2 15:04:05 2006"))
+	a.fields[key] = t.Format(time.RFC3339)
+}
+
+func (a *artifact) result() (string, map[string]any) {
+	if len(a.fields) == 0 {
+		return a.desc.String(), nil
+	}
+	return a.desc.String(), a.fields
+}
+
+// utf16z decodes NUL-terminated UTF-16LE text.
+func utf16z(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		u := binary.LittleEndian.Uint16(b[i:])
+		if u == 0 {
+			break
+		}
+		units = append(units, u)
+	}
+	return string(utf16.Decode(units))
+}
+
+// cstring decodes NUL-terminated ANSI text byte for byte as Latin-1, as
+// decodeCodepage does, which matches Windows-1252 outside 0x80-0x9F.
+func cstring(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return decodeCodepage(b[:i], 1252)
+		}
+	}
+	return decodeCodepage(b, 1252)
+}
+
+// Shell link flags (MS-SHLLINK 2.1.1).
+const (
+	lnkHasIDList     = 0x01
+	lnkHasLinkInfo   = 0x02
+	lnkHasName       = 0x04
+	lnkHasRelPath    = 0x08
+	lnkHasWorkingDir = 0x10
+	lnkHasArguments  = 0x20
+	lnkHasIconLoc    = 0x40
+	lnkIsUnicode     = 0x80
+)
+
+// describeLnk reports the target path, arguments and the target's
+// timestamps recorded in a shell link.
+func describeLnk(b []byte, file *source) (string, map[string]any) {
+	a := newArtifact("Windows shortcut")
+	if len(b) < 0x4C {
+		return a.result()
+	}
+	flags := peekLe(b[0x14:], 4)
+	target := ""
+	pos := 0x4C
+	if flags&lnkHasIDList != 0 {
+		if pos+2 > len(b) {
+			return a.result()
+		}
+		pos += 2 + peekLe(b[pos:], 2)
+	}
+	if flags&lnkHasLinkInfo != 0 {
+		head, ok := readAt(b, file, pos, 4)
+		if !ok {
+			return a.result()
+		}
+		size := peekLe(head, 4)
+		info, ok := readAt(b, file, pos, size)
+		if !ok || size < 0x1C {
+			return a.result()
+		}
+		target = lnkInfoTarget(info)
+		pos += size
+	}
+
+	// StringData: counted strings in a fixed order, each present if its
+	// flag is set.
+	strs := map[int]string{}
+	for _, flag := range []int{lnkHasName, lnkHasRelPath, lnkHasWorkingDir, lnkHasArguments, lnkHasIconLoc} {
+		if flags&flag == 0 {
+			continue
+		}
+		head, ok := readAt(b, file, pos, 2)
+		if !ok {
+			break
+		}
+		n := peekLe(head, 2)
+		if flags&lnkIsUnicode != 0 {
+			n *= 2
+		}
+		s, ok := readAt(b, file, pos+2, n)
+		if !ok {
+			break
+		}
+		if flags&lnkIsUnicode != 0 {
+			strs[flag] = utf16z(s)
+		} else {
+			strs[flag] = cstring(s)
+		}
+		pos += 2 + n
+	}
+	if target == "" {
+		target = strs[lnkHasRelPath]
+	}
+
+	a.text("target ", "target", target)
+	a.text("arguments ", "arguments", strs[lnkHasArguments])
+	if dir := strs[lnkHasWorkingDir]; dir != "" {
+		a.fields["working_dir"] = dir
+	}
+	a.time("created", "created", binary.LittleEndian.Uint64(b[0x1C:]))
+	a.time("modified", "modified", binary.LittleEndian.Uint64(b[0x2C:]))
+	a.time("accessed", "accessed", binary.LittleEndian.Uint64(b[0x24:]))
+	return a.result()
+}
+
+// lnkInfoTarget joins the local or network base path of a LinkInfo
+// structure with its common path suffix, preferring the Unicode copies.
+func lnkInfoTarget(info []byte) string {
+	str := func(off int, unicode bool) string {
+		if off <= 0 || off >= len(info) {
+			return ""
+		}
+		if unicode {
+			return utf16z(info[off:])
+		}
+		return cstring(info[off:])
+	}
+	headerSize := peekLe(info[4:], 4)
+	liFlags := peekLe(info[8:], 4)
+	hasUnicode := headerSize >= 0x24 && len(info) >= 0x24
+
+	suffix := str(peekLe(info[24:], 4), false)
+	if hasUnicode && peekLe(info[32:], 4) != 0 {
+		suffix = str(peekLe(info[32:], 4), true)
+	}
+	if liFlags&0x01 != 0 {
+		base := str(peekLe(info[16:], 4), false)
+		if hasUnicode && peekLe(info[28:], 4) != 0 {
+			base = str(peekLe(info[28:], 4), true)
+		}
+		if base != "" {
+			return base + suffix
+		}
+	}
+	if net := peekLe(info[20:], 4); liFlags&0x02 != 0 && net > 0 && net+0x14 <= len(info) {
+		// CommonNetworkRelativeLink: the share name, in Unicode too when
+		// its header is longer than 0x14 bytes.
+		name := str(net+peekLe(info[net+8:], 4), false)
+		if off := peekLe(info[net+8:], 4); off > 0x14 && net+0x18 <= len(info) {
+			name = str(net+peekLe(info[net+0x14:], 4), true)
+		}
+		if name != "" && suffix != "" {
+			return name + `\` + suffix
+		}
+		return name
+	}
+	return ""
+}
+
+var prefetchWindows = map[int]string{17: "Windows XP", 23: "Windows Vista/7", 26: "Windows 8.1", 30: "Windows 10"}
+
+// describePrefetch reports the executable, run count and last run time of
+// a Prefetch file, first decompressing the "MAM\x04" (Xpress Huffman)
+// container Windows 8 and later write.
+func describePrefetch(b []byte, file *source) (string, map[string]any) {
+	a := newArtifact("Windows Prefetch file")
+	data := b
+	if hasPrefix(b, "MAM") && len(b) >= 8 {
+		a.fields["compressed"] = true
+		if b[3]&0x0F != 4 {
+			return a.result()
+		}
+		// A set high bit adds a CRC32 before the data.
+		start := 8
+		if b[3]&0x80 != 0 {
+			start = 12
+		}
+		in := b
+		if file != nil {
+			in, _ = readAt(b, file, 0, int(min(file.size, 1<<20)))
+		}
+		if len(in) <= start {
+			return a.result()
+		}
+		var ok bool
+		// Everything reported is in the first 4KiB.
+		if data, ok = decompressXpressHuffman(in[start:], min(peekLe(b[4:], 4), 4096)); !ok {
+			return a.result()
+		}
+	}
+	if len(data) < 0x58 || !equal(data[4:8], "SCCA") {
+		return a.result()
+	}
+
+	version := peekLe(data, 4)
+	a.fields["version"] = version
+	if w := prefetchWindows[version]; w != "" {
+		a.desc.WriteString(" (" + w + " format)")
+	}
+	if name := utf16z(data[0x10:0x4C]); name != "" && isPrintableASCII(name) {
+		a.desc.WriteString(", " + name)
+		a.fields["executable"] = name
+	}
+	lastRun, runCount := 0, 0
+	switch version {
+	case 17:
+		lastRun, runCount = 0x78, 0x90
+	case 23:
+		lastRun, runCount = 0x80, 0x98
+	case 26, 30:
+		lastRun, runCount = 0x80, 0xD0
+		// Later Windows 10 and 11 builds write a file information block
+		// 8 bytes shorter, which shows as a smaller metrics array offset.
+		if version == 30 && peekLe(data[0x54:], 4) <= 0x12C {
+			runCount = 0xC8
+		}
+	}
+	if runCount > 0 && runCount+4 <= len(data) {
+		n := peekLe(data[runCount:], 4)
+		fmt.Fprintf(&a.desc, ", run count %d", n)
+		a.fields["run_count"] = n
+		// The first of up to eight last-run times is the latest.
+		a.time("last run", "last_run", binary.LittleEndian.Uint64(data[lastRun:]))
+	}
+	return a.result()
+}
+
+// describeEvtx reports the version, chunk count, next record number and the
+// dirty and full flags of an event log's file header.
+func describeEvtx(b []byte, _ *source) (string, map[string]any) {
+	a := newArtifact("Windows Event Log")
+	if len(b) < 128 {
+		return a.result()
+	}
+	version := fmt.Sprintf("%d.%d", peekLe(b[38:], 2), peekLe(b[36:], 2))
+	chunks := peekLe(b[42:], 2)
+	next := binary.LittleEndian.Uint64(b[24:])
+	fmt.Fprintf(&a.desc, ", version %s, %s, next record %d", version, plural(int64(chunks), "chunk"), next)
+	a.fields["version"] = version
+	a.fields["chunks"] = chunks
+	a.fields["next_record"] = next
+	flags := peekLe(b[120:], 4)
+	if flags&0x01 != 0 {
+		a.desc.WriteString(", dirty")
+	}
+	if flags&0x02 != 0 {
+		a.desc.WriteString(", full")
+		a.fields["full"] = true
+	}
+	a.fields["dirty"] = flags&0x01 != 0
+	return a.result()
+}
+
+// describeRegistryHive reports the base block of a registry hive: format
+// version, the hive's file name, last written time, and whether its
+// sequence numbers disagree (a write was interrupted and the transaction
+// logs are needed).
+func describeRegistryHive(b []byte, _ *source) (string, map[string]any) {
+	a := newArtifact("Windows Registry hive")
+	if len(b) < 0x70 {
+		return a.result()
+	}
+	version := fmt.Sprintf("%d.%d", peekLe(b[20:], 4), peekLe(b[24:], 4))
+	a.desc.WriteString(", version " + version)
+	a.fields["version"] = version
+	if name := strings.TrimSpace(utf16z(b[0x30:0x70])); isPrintableASCII(name) {
+		a.text("name ", "hive_name", name)
+	}
+	a.time("last written", "last_written", binary.LittleEndian.Uint64(b[12:]))
+	dirty := peekLe(b[4:], 4) != peekLe(b[8:], 4)
+	if dirty {
+		a.desc.WriteString(", dirty")
+	}
+	a.fields["dirty"] = dirty
+	return a.result()
+}
+
+// describeRecycleBinI reports the original path, size and deletion time
+// from a Recycle Bin $I file.
+func describeRecycleBinI(b []byte, _ *source) (string, map[string]any) {
+	a := newArtifact("Windows Recycle Bin metadata")
+	if len(b) < 24 {
+		return a.result()
+	}
+	var path string
+	switch binary.LittleEndian.Uint64(b) {
+	case 1: // Vista to 8.1: a fixed MAX_PATH buffer
+		path = utf16z(b[24:min(len(b), 24+520)])
+	case 2: // Windows 10: a counted string
+		if len(b) >= 28 {
+			n := peekLe(b[24:], 4)
+			path = utf16z(b[28:min(len(b), 28+2*n)])
+		}
+	}
+	a.text("original ", "original_path", path)
+	size := binary.LittleEndian.Uint64(b[8:])
+	fmt.Fprintf(&a.desc, ", size %d", size)
+	a.fields["size"] = size
+	a.time("deleted", "deleted", binary.LittleEndian.Uint64(b[16:]))
+	return a.result()
+}
+
+var thumbcacheWindows = map[int]string{20: "Windows Vista", 21: "Windows 7", 30: "Windows 8", 31: "Windows 8.1", 32: "Windows 10"}
+
+// describeThumbcache names the Windows release whose format a thumbcache
+// database uses.
+func describeThumbcache(b []byte, _ *source) (string, map[string]any) {
+	a := newArtifact("Windows thumbnail cache")
+	if len(b) < 8 {
+		return a.result()
+	}
+	version := peekLe(b[4:], 4)
+	a.fields["version"] = version
+	if w := thumbcacheWindows[version]; w != "" {
+		a.desc.WriteString(" (" + w + " format)")
+	}
+	return a.result()
+}
@@ -0,0 +1,1099 @@
+package magic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// imageInfo is the pixel geometry image matchers report, rendered as
+// "640 x 480, 8-bit RGB". depth is bits per channel, or index bits for
+// colormap images; bpp, when set, is shown in its place for formats whose
+// convention is bits per pixel (BMP, ICO, Targa, DDS).
+type imageInfo struct {
+	width, height int
+	depth, bpp    int
+	color         string // "RGB", "RGBA", "grayscale", "colormap", "CMYK", "YCbCr", "CFA", ...
+}
+
+// String renders the geometry as a description suffix, with its leading
+// separator, or "" when nothing is known.
+func (im imageInfo) String() string {
+	var parts []string
+	if im.width > 0 && im.height > 0 {
+		parts = append(parts, fmt.Sprintf("%d x %d", im.width, im.height))
+	}
+	if pix := im.pixels(); pix != "" {
+		parts = append(parts, pix)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return ", " + strings.Join(parts, ", ")
+}
+
+// pixels renders the bit depth and colour model alone.
+func (im imageInfo) pixels() string {
+	bits := im.bpp
+	if bits == 0 {
+		bits = im.depth
+	}
+	if bits > 0 {
+		return strings.TrimSpace(fmt.Sprintf("%d-bit %s", bits, im.color))
+	}
+	return im.color
+}
+
+func (im imageInfo) fields() map[string]any {
+	f := map[string]any{}
+	if im.width > 0 && im.height > 0 {
+		f["width"] = im.width
+		f["height"] = im.height
+	}
+	if im.depth > 0 {
+		f["bit_depth"] = im.depth
+	}
+	if im.bpp > 0 {
+		f["bits_per_pixel"] = im.bpp
+	}
+	if im.color != "" {
+		f["color"] = im.color
+	}
+	if len(f) == 0 {
+		return nil
+	}
+	return f
+}
+
+// packedDepth is the per-channel depth of a packed pixel of bpp bits.
+func packedDepth(bpp int) int {
+	switch bpp {
+	case 15, 16:
+		return 5
+	case 24, 32:
+		return 8
+	case 48, 64:
+		return 16
+	}
+	return 0
+}
+
+// imageDetails adapts a geometry parser to fileMatcher.details for formats
+// whose description is just the base name plus the geometry.
+func imageDetails(base string, parse func([]byte, *source) imageInfo) func([]byte, *source) (string, map[string]any) {
+	return func(b []byte, file *source) (string, map[string]any) {
+		im := parse(b, file)
+		return base + im.String(), im.fields()
+	}
+}
+
+// jpegSOF reports whether marker starts a frame header (SOF0-SOF15 less
+// DHT, JPG and DAC).
+func jpegSOF(marker byte) bool {
+	return marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC
+}
+
+// jpegSegments calls fn with the marker, payload offset and payload length
+// of each segment up to the start of scan, until fn returns false.
+func jpegSegments(b []byte, file *source, fn func(marker byte, off int64, length int) bool) {
+	off := int64(2)
+	for i := 0; i < 1024; i++ {
+		hdr, ok := readRegion(b, file, off, 4)
+		if !ok || hdr[0] != 0xFF {
+			return
+		}
+		marker := hdr[1]
+		switch {
+		case marker == 0xFF: // fill byte
+			off++
+			continue
+		case marker == 0x01 || marker == 0xD8 || marker >= 0xD0 && marker <= 0xD7:
+			off += 2
+			continue
+		case marker == 0xD9 || marker == 0xDA:
+			return
+		}
+		length := peekBe(hdr[2:], 2)
+		if length < 2 || !fn(marker, off+4, length-2) {
+			return
+		}
+		off += 2 + int64(length)
+	}
+}
+
+func describeJPEG(b []byte, file *source) (string, map[string]any) {
+	var im imageInfo
+	progressive := false
+	adobe := -1 // APP14 colour transform
+	jpegSegments(b, file, func(marker byte, off int64, length int) bool {
+		switch {
+		case marker == 0xEE && length >= 12:
+			if seg, ok := readRegion(b, file, off, 12); ok && hasPrefix(seg, "Adobe") {
+				adobe = int(seg[11])
+			}
+		case jpegSOF(marker) && length >= 6:
+			seg, ok := readRegion(b, file, off, 6)
+			if !ok {
+				return false
+			}
+			im = imageInfo{depth: int(seg[0]), height: peekBe(seg[1:], 2), width: peekBe(seg[3:], 2)}
+			switch seg[5] {
+			case 1:
+				im.color = "grayscale"
+			case 3:
+				im.color = "YCbCr"
+				if adobe == 0 {
+					im.color = "RGB"
+				}
+			case 4:
+				im.color = "CMYK"
+				if adobe == 2 {
+					im.color = "YCCK"
+				}
+			}
+			progressive = marker == 0xC2 || marker == 0xC6 || marker == 0xCA || marker == 0xCE
+			return false
+		}
+		return true
+	})
+	if im.width <= 0 || im.height <= 0 {
+		return "JPEG / jpg image data", nil
+	}
+	desc := "JPEG / jpg image data" + im.String()
+	if progressive {
+		desc += ", progressive"
+	}
+	return desc, im.fields()
+}
+
+func describeGIF(b []byte, _ *source) (string, map[string]any) {
+	if len(b) < 10 {
+		return "GIF image data", nil
+	}
+	version := string(b[3:6])
+	im := imageInfo{width: peekLe(b[6:], 2), height: peekLe(b[8:], 2)}
+	if len(b) > 10 && b[10]&0x80 != 0 {
+		// Global colour table of 2^(n+1) entries.
+		im.depth = int(b[10]&0x07) + 1
+		im.color = "colormap"
+	}
+	return fmt.Sprintf("GIF image data, version %s", version) + im.String(), im.fields()
+}
+
+func describeBMP(b []byte, _ *source) (string, map[string]any) {
+	if len(b) < 30 {
+		return "BMP image", nil
+	}
+	width := int(int32(peekLe(b[18:], 4)))
+	height := int(int32(peekLe(b[22:], 4)))
+	if height < 0 {
+		height = -height
+	}
+	if width <= 0 || height <= 0 {
+		return "BMP image", nil
+	}
+	im := imageInfo{width: width, height: height, bpp: peekLe(b[28:], 2)}
+	switch {
+	case im.bpp > 0 && im.bpp <= 8:
+		im.depth, im.color = im.bpp, "colormap"
+	case im.bpp == 32 && peekLe(b[14:], 4) >= 56 && len(b) >= 70 && peekLe(b[66:], 4) != 0:
+		// BITMAPV3INFOHEADER and later carry an alpha mask.
+		im.depth, im.color = 8, "RGBA"
+	case im.bpp >= 16:
+		im.depth, im.color = packedDepth(im.bpp), "RGB"
+	}
+	return "BMP image" + im.String(), im.fields()
+}
+
+// tiffPhotometric names TIFF PhotometricInterpretation values.
+var tiffPhotometric = map[int]string{
+	0: "grayscale", 1: "grayscale", 2: "RGB", 3: "colormap", 4: "mask",
+	5: "CMYK", 6: "YCbCr", 8: "CIELab", 32803: "CFA", 34892: "linear raw",
+}
+
+// tiffImage reads the geometry recorded in one IFD.
+func tiffImage(t *tiffReader, ifd map[uint16]tiffEntry) imageInfo {
+	var im imageInfo
+	im.width, _ = t.tag(ifd, 0x0100)
+	im.height, _ = t.tag(ifd, 0x0101)
+	im.depth, _ = t.tag(ifd, 0x0102)
+	if im.width == 0 {
+		// Panasonic RW2 keeps the sensor size in private tags.
+		im.width, _ = t.tag(ifd, 0x0002)
+		im.height, _ = t.tag(ifd, 0x0003)
+		im.depth, _ = t.tag(ifd, 0x000A)
+	}
+	if p, ok := t.tag(ifd, 0x0106); ok {
+		im.color = tiffPhotometric[p]
+		if _, alpha := ifd[0x0152]; alpha && p == 2 {
+			im.color = "RGBA"
+		}
+	}
+	return im
+}
+
+func describeTIFF(b []byte, file *source) (string, map[string]any) {
+	im := imageInfo{}
+	if t := newTiffReader(b, file, 0); t != nil {
+		if ifd, _ := t.ifd(t.first); ifd != nil {
+			im = tiffImage(t, ifd)
+			if im.color == "" && im.depth == 1 {
+				im.color = "bilevel"
+			}
+		}
+	}
+	return "TIFF image data" + im.String(), im.fields()
+}
+
+// rawImage picks the sensor image of a TIFF-based raw file: the IFD, in
+// the IFD0 chain or its SubIFDs, holding CFA or linear raw data, otherwise
+// the largest one (the full-size preview of Canon CR2). Raw data is
+// reported as CFA whatever the preview's photometric interpretation.
+func rawImage(b []byte, file *source) imageInfo {
+	t := newTiffReader(b, file, 0)
+	if t == nil {
+		return imageInfo{}
+	}
+	var best imageInfo
+	consider := func(ifd map[uint16]tiffEntry) bool {
+		im := tiffImage(t, ifd)
+		if im.color == "CFA" || im.color == "linear raw" {
+			if im.color == "linear raw" {
+				im.color = "RGB"
+			}
+			best = im
+			return true
+		}
+		if im.width*im.height > best.width*best.height {
+			best = imageInfo{width: im.width, height: im.height}
+		}
+		return false
+	}
+	off := t.first
+	for i := 0; i < 8 && off != 0; i++ {
+		ifd, next := t.ifd(off)
+		if ifd == nil {
+			break
+		}
+		if consider(ifd) {
+			return best
+		}
+		if sub, ok := ifd[0x014A]; ok {
+			for j := 0; j < int(sub.count) && j < 8; j++ {
+				if o, ok := t.uint(sub, j); ok {
+					if subIFD, _ := t.ifd(int64(o)); subIFD != nil && consider(subIFD) {
+						return best
+					}
+				}
+			}
+		}
+		off = next
+	}
+	if best.width > 0 {
+		best.color = "CFA"
+	}
+	return best
+}
+
+// rafImage reads the raw dimensions from the tag directory of a Fuji RAF
+// file, whose offset is at byte 92.
+func rafImage(b []byte, file *source) imageInfo {
+	hdr, ok := readRegion(b, file, 92, 8)
+	if !ok {
+		return imageInfo{}
+	}
+	dir, ok := readAt(b, file, peekBe(hdr, 4), min(peekBe(hdr[4:], 4), 64<<10))
+	if !ok || len(dir) < 4 {
+		return imageInfo{}
+	}
+	n := peekBe(dir, 4)
+	for p := 4; n > 0 && p+4 <= len(dir); n-- {
+		tag, size := peekBe(dir[p:], 2), peekBe(dir[p+2:], 2)
+		if p+4+size > len(dir) {
+			break
+		}
+		if tag == 0x0100 && size >= 4 {
+			return imageInfo{height: peekBe(dir[p+4:], 2), width: peekBe(dir[p+6:], 2), color: "CFA"}
+		}
+		p += 4 + size
+	}
+	return imageInfo{}
+}
+
+// bmffBoxes calls fn with the type and payload of each ISO base media box
+// in data, until fn returns false.
+func bmffBoxes(data []byte, fn func(typ string, payload []byte) bool) {
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data))
+		typ := string(data[4:8])
+		hdr := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return
+			}
+			size, hdr = binary.BigEndian.Uint64(data[8:]), 16
+		}
+		if size < hdr || size > uint64(len(data)) {
+			return
+		}
+		if !fn(typ, data[hdr:size]) {
+			return
+		}
+		data = data[size:]
+	}
+}
+
+// bmffTopLevel returns the payload of the first top-level box of type typ,
+// up to 4MiB of it.
+func bmffTopLevel(b []byte, file *source, typ string) []byte {
+	size := int64(len(b))
+	if file != nil {
+		size = file.size
+	}
+	for off, i := int64(0), 0; off+8 <= size && i < 64; i++ {
+		hdr, ok := readRegion(b, file, off, 16)
+		if !ok {
+			hdr, ok = readRegion(b, file, off, 8)
+		}
+		if !ok {
+			return nil
+		}
+		boxSize, hdrLen := int64(binary.BigEndian.Uint32(hdr)), int64(8)
+		switch boxSize {
+		case 0:
+			boxSize = size - off
+		case 1:
+			if len(hdr) < 16 {
+				return nil
+			}
+			boxSize, hdrLen = int64(binary.BigEndian.Uint64(hdr[8:])), 16
+		}
+		if boxSize < hdrLen || boxSize > size-off {
+			return nil
+		}
+		if string(hdr[4:8]) == typ {
+			payload, _ := readRegion(b, file, off+hdrLen, int(min(boxSize-hdrLen, 4<<20)))
+			return payload
+		}
+		off += boxSize
+	}
+	return nil
+}
+
+// heifPrimaryProperties returns the item properties (ispe, pixi, hvcC,
+// av1C, ...) associated with the primary item of a HEIF meta box, or all of
+// them when the associations cannot be read, and all of them.
+func heifPrimaryProperties(meta []byte) (own, all [][2]string) {
+	primary := -1
+	var props [][2]string
+	var ipma []byte
+	bmffBoxes(meta, func(typ string, p []byte) bool {
+		switch typ {
+		case "pitm":
+			if len(p) >= 6 && p[0] == 0 {
+				primary = peekBe(p[4:], 2)
+			} else if len(p) >= 8 {
+				primary = peekBe(p[4:], 4)
+			}
+		case "iprp":
+			bmffBoxes(p, func(typ string, p []byte) bool {
+				switch typ {
+				case "ipco":
+					bmffBoxes(p, func(typ string, p []byte) bool {
+						props = append(props, [2]string{typ, string(p)})
+						return true
+					})
+				case "ipma":
+					ipma = p
+				}
+				return true
+			})
+		}
+		return true
+	})
+	if primary < 0 || len(ipma) < 8 {
+		return props, props
+	}
+	version, large := ipma[0], ipma[3]&1 != 0
+	p := ipma[8:]
+	for n := peekBe(ipma[4:], 4); n > 0; n-- {
+		idSize := 2
+		if version >= 1 {
+			idSize = 4
+		}
+		if len(p) < idSize+1 {
+			break
+		}
+		id, count := peekBe(p, idSize), int(p[idSize])
+		p = p[idSize+1:]
+		entrySize := 1
+		if large {
+			entrySize = 2
+		}
+		if len(p) < count*entrySize {
+			break
+		}
+		if id != primary {
+			p = p[count*entrySize:]
+			continue
+		}
+		var own [][2]string
+		for i := 0; i < count; i++ {
+			index := peekBe(p[i*entrySize:], entrySize) & (1<<(8*entrySize-1) - 1)
+			if index >= 1 && index <= len(props) {
+				own = append(own, props[index-1])
+			}
+		}
+		return own, props
+	}
+	return props, props
+}
+
+// heifImage reads the primary image's size (ispe) and its bit depth and
+// colour model from pixi or the HEVC or AV1 decoder configuration.
+func heifImage(b []byte, file *source) imageInfo {
+	meta := bmffTopLevel(b, file, "meta")
+	if len(meta) < 4 {
+		return imageInfo{}
+	}
+	var im imageInfo
+	own, all := heifPrimaryProperties(meta[4:])
+	// A grid image has no decoder configuration of its own: its tiles' is
+	// the next best source of depth and colour model.
+	for _, prop := range append(own, all...) {
+		p := []byte(prop[1])
+		switch prop[0] {
+		case "ispe":
+			if len(p) >= 12 && im.width == 0 {
+				im.width, im.height = peekBe(p[4:], 4), peekBe(p[8:], 4)
+			}
+		case "pixi":
+			if len(p) >= 6 && p[4] > 0 && im.depth == 0 {
+				im.depth = int(p[5])
+				if p[4] == 1 {
+					im.color = "grayscale"
+				}
+			}
+		case "hvcC":
+			if len(p) >= 19 && im.color == "" {
+				im.color = "YCbCr"
+				if p[16]&0x03 == 0 {
+					im.color = "grayscale"
+				}
+				if im.depth == 0 {
+					im.depth = int(p[17]&0x07) + 8
+				}
+			}
+		case "av1C":
+			if len(p) >= 3 && im.color == "" {
+				im.color = "YCbCr"
+				if p[2]&0x10 != 0 {
+					im.color = "grayscale"
+				}
+				if im.depth == 0 {
+					im.depth = 8
+					if p[2]&0x40 != 0 {
+						im.depth = 10
+						if p[2]&0x20 != 0 {
+							im.depth = 12
+						}
+					}
+				}
+			}
+		}
+	}
+	return im
+}
+
+// heifItemOfType returns the ID of the first item of the given type in a
+// HEIF meta box's item information.
+func heifItemOfType(meta []byte, itemType string) (int, bool) {
+	id, found := 0, false
+	bmffBoxes(meta, func(typ string, p []byte) bool {
+		if typ != "iinf" || len(p) < 6 {
+			return true
+		}
+		entries := p[6:]
+		if p[0] != 0 {
+			entries = p[8:]
+		}
+		bmffBoxes(entries, func(typ string, p []byte) bool {
+			switch {
+			case typ != "infe":
+			case p[0] == 2 && len(p) >= 12 && string(p[8:12]) == itemType:
+				id, found = peekBe(p[4:], 2), true
+			case p[0] == 3 && len(p) >= 14 && string(p[10:14]) == itemType:
+				id, found = peekBe(p[4:], 4), true
+			}
+			return !found
+		})
+		return false
+	})
+	return id, found
+}
+
+// heifItemLocation returns the file offset and length of a single-extent
+// item stored in the file itself (construction method 0).
+func heifItemLocation(meta []byte, item int) (int64, int64, bool) {
+	var off, length int64
+	found := false
+	bmffBoxes(meta, func(typ string, p []byte) bool {
+		if typ != "iloc" || len(p) < 8 {
+			return true
+		}
+		version := p[0]
+		offSize, lenSize := int(p[4]>>4), int(p[4]&0x0F)
+		baseSize, indexSize := int(p[5]>>4), int(p[5]&0x0F)
+		idSize := 2
+		if version == 2 {
+			idSize = 4
+		}
+		if version == 0 {
+			indexSize = 0
+		}
+		r := isobmffFields{b: p[6:]}
+		for n := r.uint(idSize); n > 0 && !r.bad; n-- {
+			id := r.uint(idSize)
+			method := 0
+			if version >= 1 {
+				method = r.uint(2) & 0x0F
+			}
+			r.uint(2) // data reference index
+			base := r.uint(baseSize)
+			extents := r.uint(2)
+			for i := 0; i < extents && !r.bad; i++ {
+				r.uint(indexSize)
+				extOff, extLen := r.uint(offSize), r.uint(lenSize)
+				if id == item && extents == 1 && method == 0 {
+					off, length, found = int64(base+extOff), int64(extLen), !r.bad
+				}
+			}
+			if id == item {
+				break
+			}
+		}
+		return false
+	})
+	return off, length, found
+}
+
+// isobmffFields reads big-endian fields of 0, 2, 4 or 8 bytes, remembering
+// any overrun.
+type isobmffFields struct {
+	b   []byte
+	bad bool
+}
+
+func (r *isobmffFields) uint(n int) int {
+	if n != 0 && n != 2 && n != 4 && n != 8 || n > len(r.b) {
+		r.bad = true
+		return 0
+	}
+	v := 0
+	for _, c := range r.b[:n] {
+		v = v<<8 | int(c)
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+// cr3Image reads the largest CRAW sample entry of a Canon CR3 file: the
+// raw sensor data, next to the JPEG previews.
+func cr3Image(b []byte, file *source) imageInfo {
+	var im imageInfo
+	var walk func(data []byte)
+	walk = func(data []byte) {
+		bmffBoxes(data, func(typ string, p []byte) bool {
+			switch typ {
+			case "trak", "mdia", "minf", "stbl":
+				walk(p)
+			case "stsd":
+				if len(p) >= 8 {
+					bmffBoxes(p[8:], func(typ string, p []byte) bool {
+						if typ == "CRAW" && len(p) >= 28 {
+							w, h := peekBe(p[24:], 2), peekBe(p[26:], 2)
+							if w*h > im.width*im.height {
+								im = imageInfo{width: w, height: h, color: "CFA"}
+							}
+						}
+						return true
+					})
+				}
+			}
+			return true
+		})
+	}
+	walk(bmffTopLevel(b, file, "moov"))
+	return im
+}
+
+// psdColorModes names Photoshop colour modes.
+var psdColorModes = map[int]string{
+	0: "bitmap", 1: "grayscale", 2: "colormap", 3: "RGB", 4: "CMYK",
+	7: "multichannel", 8: "duotone", 9: "CIELab",
+}
+
+func psdImage(b []byte, _ *source) imageInfo {
+	if len(b) < 26 {
+		return imageInfo{}
+	}
+	im := imageInfo{
+		height: peekBe(b[14:], 4),
+		width:  peekBe(b[18:], 4),
+		depth:  peekBe(b[22:], 2),
+		color:  psdColorModes[peekBe(b[24:], 2)],
+	}
+	if im.color == "RGB" && peekBe(b[12:], 2) > 3 {
+		im.color = "RGBA"
+	}
+	return im
+}
+
+func describeWebP(b []byte, _ *source) (string, map[string]any) {
+	if len(b) < 16 {
+		return "Google WebP file", nil
+	}
+	im := imageInfo{depth: 8, color: "RGB"}
+	var desc string
+	switch {
+	case equal(b[12:16], "VP8 "):
+		// Lossy VP8 key frame: 3-byte frame tag + start code 0x9D 0x01 0x2A.
+		desc = "Google WebP file (lossy)"
+		if len(b) >= 30 && b[20]&0x01 == 0 && b[23] == 0x9D && b[24] == 0x01 && b[25] == 0x2A {
+			im.width = (int(b[26]) | int(b[27])<<8) & 0x3FFF
+			im.height = (int(b[28]) | int(b[29])<<8) & 0x3FFF
+			if im.width > 0 && im.height > 0 {
+				desc = fmt.Sprintf("Google WebP file (lossy, %d x %d)", im.width, im.height)
+			}
+		}
+	case equal(b[12:16], "VP8L"):
+		// Lossless: signature 0x2F, then 14-bit width-1, 14-bit height-1
+		// and the alpha hint.
+		desc = "Google WebP file (lossless)"
+		if len(b) >= 25 && b[20] == 0x2F {
+			bits := peekLe(b[21:], 4)
+			im.width, im.height = bits&0x3FFF+1, bits>>14&0x3FFF+1
+			if bits>>28&1 != 0 {
+				im.color = "RGBA"
+			}
+			desc = fmt.Sprintf("Google WebP file (lossless, %d x %d)", im.width, im.height)
+		}
+	case equal(b[12:16], "VP8X"):
+		// Extended: flags at byte 20; canvas dims at bytes 24–29 (3-byte LE each, value = dim-1).
+		desc = "Google WebP file (extended)"
+		if len(b) >= 30 {
+			flags := b[20]
+			im.width = (int(b[24]) | int(b[25])<<8 | int(b[26])<<16) + 1
+			im.height = (int(b[27]) | int(b[28])<<8 | int(b[29])<<16) + 1
+			variant := "extended"
+			if flags&0x02 != 0 {
+				variant = "animated"
+			}
+			if flags&0x10 != 0 {
+				im.color = "RGBA"
+			}
+			desc = fmt.Sprintf("Google WebP file (%s, %d x %d)", variant, im.width, im.height)
+		}
+	default:
+		return "Google WebP file", nil
+	}
+	return desc + ", " + im.pixels(), im.fields()
+}
+
+// icoImage reports the largest image in an icon or cursor directory.
+func describeIconDir(base string) func([]byte, *source) (string, map[string]any) {
+	return func(b []byte, _ *source) (string, map[string]any) {
+		count := peekLe(b[4:], 2)
+		if count == 0 || len(b) < 6+16*count {
+			return base, nil
+		}
+		var im imageInfo
+		for i := 0; i < count; i++ {
+			e := b[6+16*i:]
+			w, h, bpp := int(e[0]), int(e[1]), peekLe(e[6:], 2)
+			if w == 0 {
+				w = 256
+			}
+			if h == 0 {
+				h = 256
+			}
+			if w*h > im.width*im.height || w*h == im.width*im.height && bpp > im.bpp {
+				im = imageInfo{width: w, height: h, bpp: bpp}
+			}
+		}
+		switch {
+		case im.bpp > 0 && im.bpp <= 8:
+			im.depth, im.color = im.bpp, "colormap"
+		case im.bpp == 32:
+			im.depth, im.color = 8, "RGBA"
+		case im.bpp > 8:
+			im.depth, im.color = packedDepth(im.bpp), "RGB"
+		}
+		noun := "icons"
+		if count == 1 {
+			noun = "icon"
+		}
+		f := im.fields()
+		f["images"] = count
+		return fmt.Sprintf("%s, %d %s", base, count, noun) + im.String(), f
+	}
+}
+
+// icnsSizes gives the pixel size of each ICNS element type.
+var icnsSizes = map[string]int{
+	"ICON": 32, "ICN#": 32, "icm#": 16, "icm4": 16, "icm8": 16, "ics#": 16, "ics4": 16, "ics8": 16,
+	"is32": 16, "il32": 32, "ih32": 48, "it32": 128, "icp4": 16, "icp5": 32, "icp6": 64,
+	"ic07": 128, "ic08": 256, "ic09": 512, "ic10": 1024, "ic11": 32, "ic12": 64, "ic13": 256, "ic14": 512,
+	"ic04": 16, "ic05": 32,
+}
+
+func icnsImage(b []byte, file *source) imageInfo {
+	var im imageInfo
+	size := int64(len(b))
+	if file != nil {
+		size = file.size
+	}
+	for off, i := int64(8), 0; off+8 <= size && i < 256; i++ {
+		hdr, ok := readRegion(b, file, off, 8)
+		if !ok {
+			break
+		}
+		n := int64(peekBe(hdr[4:], 4))
+		if s := icnsSizes[string(hdr[:4])]; s > im.width {
+			im = imageInfo{width: s, height: s, depth: 8, color: "RGBA"}
+		}
+		if n < 8 {
+			break
+		}
+		off += n
+	}
+	return im
+}
+
+func tgaImage(b []byte, _ *source) imageInfo {
+	if len(b) < 18 {
+		return imageInfo{}
+	}
+	im := imageInfo{width: peekLe(b[12:], 2), height: peekLe(b[14:], 2), bpp: int(b[16])}
+	switch b[2] &^ 8 { // 9-11 are the RLE variants of 1-3
+	case 1:
+		im.depth, im.color = im.bpp, "colormap"
+	case 2:
+		im.depth, im.color = packedDepth(im.bpp), "RGB"
+		if b[17]&0x0F != 0 {
+			im.color = "RGBA"
+		}
+	case 3:
+		im.depth, im.color = im.bpp, "grayscale"
+	}
+	return im
+}
+
+func describeDDS(b []byte, _ *source) (string, map[string]any) {
+	if len(b) < 128 || peekLe(b[4:], 4) != 124 {
+		return "DDS image data", nil
+	}
+	im := imageInfo{height: peekLe(b[12:], 4), width: peekLe(b[16:], 4)}
+	flags := peekLe(b[80:], 4)
+	if flags&0x04 != 0 {
+		// Block-compressed: the FourCC names the codec.
+		f := im.fields()
+		if f == nil {
+			f = map[string]any{}
+		}
+		fourCC := strings.TrimRight(string(b[84:88]), "\x00 ")
+		f["compression"] = fourCC
+		return "DDS image data" + im.String() + ", " + fourCC + " compressed", f
+	}
+	im.bpp = peekLe(b[88:], 4)
+	switch {
+	case flags&0x20000 != 0:
+		im.depth, im.color = im.bpp, "grayscale"
+	case flags&0x40 != 0:
+		im.depth, im.color = packedDepth(im.bpp), "RGB"
+		if flags&0x01 != 0 {
+			im.color = "RGBA"
+		}
+	}
+	return "DDS image data" + im.String(), im.fields()
+}
+
+// exrImage reads the dataWindow and channels attributes of an OpenEXR
+// header: name, type, size and value, until an empty name.
+func exrImage(b []byte, _ *source) imageInfo {
+	var im imageInfo
+	p := b[min(len(b), 8):]
+	for i := 0; i < 256; i++ {
+		name, rest, ok := bytes.Cut(p, []byte{0})
+		if !ok || len(name) == 0 {
+			break
+		}
+		typ, rest, ok := bytes.Cut(rest, []byte{0})
+		if !ok || len(rest) < 4 {
+			break
+		}
+		size := peekLe(rest, 4)
+		if size < 0 || 4+size > len(rest) {
+			break
+		}
+		value := rest[4 : 4+size]
+		switch {
+		case string(name) == "dataWindow" && string(typ) == "box2i" && size == 16:
+			im.width = int(int32(peekLe(value[8:], 4))-int32(peekLe(value, 4))) + 1
+			im.height = int(int32(peekLe(value[12:], 4))-int32(peekLe(value[4:], 4))) + 1
+		case string(name) == "channels" && string(typ) == "chlist":
+			im.depth, im.color = exrChannels(value)
+		}
+		p = rest[4+size:]
+	}
+	return im
+}
+
+// exrChannels derives depth and colour model from a channel list: name,
+// pixel type (0 uint, 1 half, 2 float) and 12 bytes of sampling data.
+func exrChannels(list []byte) (int, string) {
+	names := map[string]bool{}
+	depth := 0
+	for len(list) > 0 && list[0] != 0 {
+		name, rest, ok := bytes.Cut(list, []byte{0})
+		if !ok || len(rest) < 16 {
+			break
+		}
+		names[string(name)] = true
+		if d := [3]int{32, 16, 32}; peekLe(rest, 4) < 3 {
+			depth = max(depth, d[peekLe(rest, 4)])
+		}
+		list = rest[16:]
+	}
+	switch {
+	case names["R"] && names["G"] && names["B"] && names["A"]:
+		return depth, "RGBA"
+	case names["R"] && names["G"] && names["B"]:
+		return depth, "RGB"
+	case names["Y"] && names["RY"] && names["BY"]:
+		return depth, "YCbCr"
+	case names["Y"]:
+		return depth, "grayscale"
+	}
+	return depth, ""
+}
+
+// hdrImage reads the resolution line after the Radiance header's blank
+// line, e.g. "-Y 768 +X 1024".
+func hdrImage(b []byte, _ *source) imageInfo {
+	head, body, ok := bytes.Cut(b, []byte("\n\n"))
+	if !ok {
+		return imageInfo{}
+	}
+	im := imageInfo{color: "RGB"}
+	if bytes.Contains(head, []byte("FORMAT=32-bit_rle_xyze")) {
+		im.color = "XYZ"
+	}
+	line, _, _ := bytes.Cut(body, []byte("\n"))
+	f := strings.Fields(string(line))
+	if len(f) != 4 {
+		return im
+	}
+	a, _ := strconv.Atoi(f[1])
+	c, _ := strconv.Atoi(f[3])
+	if strings.HasSuffix(f[0], "Y") {
+		im.height, im.width = a, c
+	} else {
+		im.width, im.height = a, c
+	}
+	return im
+}
+
+// jxlBits reads the LSB-first bit fields of a JPEG XL codestream.
+type jxlBits struct {
+	b   []byte
+	pos int
+	bad bool
+}
+
+func (r *jxlBits) u(n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		if r.pos>>3 >= len(r.b) {
+			r.bad = true
+			return 0
+		}
+		v |= int(r.b[r.pos>>3]>>(r.pos&7)&1) << i
+		r.pos++
+	}
+	return v
+}
+
+// u32 reads a 2-bit selector and then the chosen {offset, bits}
+// distribution.
+func (r *jxlBits) u32(d [4][2]int) int {
+	c := d[r.u(2)]
+	return c[0] + r.u(c[1])
+}
+
+var (
+	jxlSize    = [4][2]int{{1, 9}, {1, 13}, {1, 18}, {1, 30}}
+	jxlPreview = [4][2]int{{1, 6}, {65, 8}, {321, 10}, {1345, 12}}
+	jxlPrevDiv = [4][2]int{{16, 0}, {32, 0}, {1, 5}, {33, 9}}
+	jxlEnum    = [4][2]int{{0, 0}, {1, 0}, {2, 4}, {18, 6}}
+	jxlRatios  = [8][2]int{{}, {1, 1}, {12, 10}, {4, 3}, {3, 2}, {16, 9}, {5, 4}, {2, 1}}
+)
+
+// size reads a SizeHeader or PreviewHeader.
+func (r *jxlBits) size(dist, div8Dist [4][2]int, div8Bits bool) (int, int) {
+	var h, w int
+	div8 := r.u(1) == 1
+	if div8 {
+		if div8Bits {
+			h = 8 * (r.u(5) + 1)
+		} else {
+			h = 8 * r.u32(div8Dist)
+		}
+	} else {
+		h = r.u32(dist)
+	}
+	ratio := r.u(3)
+	switch {
+	case ratio != 0:
+		w = h * jxlRatios[ratio][0] / jxlRatios[ratio][1]
+	case div8 && div8Bits:
+		w = 8 * (r.u(5) + 1)
+	case div8:
+		w = 8 * r.u32(div8Dist)
+	default:
+		w = r.u32(dist)
+	}
+	return w, h
+}
+
+// jxlImage decodes the SizeHeader and the start of ImageMetadata: enough
+// for the bit depth, and the colour space when no extra channels come
+// first.
+func jxlImage(b []byte, _ *source) imageInfo {
+	code := b
+	if !hasPrefix(b, "\xFF\x0A") {
+		// ISO BMFF container: the codestream is in jxlc, or split over
+		// jxlp boxes that start with a 4-byte index.
+		code = nil
+		bmffBoxes(b, func(typ string, p []byte) bool {
+			switch typ {
+			case "jxlc":
+				code = p
+			case "jxlp":
+				if len(p) > 4 {
+					code = p[4:]
+				}
+			}
+			return code == nil
+		})
+		if !hasPrefix(code, "\xFF\x0A") {
+			return imageInfo{}
+		}
+	}
+	r := &jxlBits{b: code[2:]}
+	var im imageInfo
+	im.width, im.height = r.size(jxlSize, [4][2]int{}, true)
+	if r.u(1) == 1 { // all_default
+		im.depth, im.color = 8, "RGB"
+		return im
+	}
+	if r.u(1) == 1 { // extra_fields
+		r.u(3) // orientation
+		if r.u(1) == 1 {
+			r.size(jxlSize, [4][2]int{}, true) // intrinsic size
+		}
+		if r.u(1) == 1 {
+			r.size(jxlPreview, jxlPrevDiv, false)
+		}
+		if r.u(1) == 1 { // animation
+			r.u32([4][2]int{{100, 0}, {1000, 0}, {1, 10}, {1, 30}})
+			r.u32([4][2]int{{1, 0}, {1001, 0}, {1, 8}, {1, 10}})
+			r.u32([4][2]int{{0, 0}, {0, 3}, {0, 16}, {0, 32}})
+			r.u(1)
+		}
+	}
+	if r.u(1) == 1 { // floating point samples
+		im.depth = r.u32([4][2]int{{32, 0}, {16, 0}, {24, 0}, {1, 6}})
+		r.u(4)
+	} else {
+		im.depth = r.u32([4][2]int{{8, 0}, {10, 0}, {12, 0}, {1, 6}})
+	}
+	r.u(1) // modular_16bit_buffers
+	extra := r.u32([4][2]int{{0, 0}, {1, 0}, {2, 4}, {1, 12}})
+	alpha := false
+	if extra > 0 {
+		// Only an all-default first extra channel (alpha) can be skipped.
+		if r.u(1) == 0 {
+			alpha = r.u32(jxlEnum) == 0
+			extra = -1
+		} else {
+			alpha = true
+		}
+	}
+	if extra == 0 || extra == 1 {
+		r.u(1) // xyb_encoded
+		im.color = "RGB"
+		if r.u(1) == 0 { // colour encoding not all default
+			if r.u(1) == 1 {
+				im.color = "" // ICC profile
+			} else if r.u32(jxlEnum) == 1 {
+				im.color = "grayscale"
+			}
+		}
+		if alpha && im.color == "RGB" {
+			im.color = "RGBA"
+		}
+	}
+	if r.bad {
+		return imageInfo{}
+	}
+	return im
+}
+
+// jp2ColorSpaces names the enumerated colour spaces of a JP2 colr box.
+var jp2ColorSpaces = map[int]string{12: "CMYK", 14: "CIELab", 16: "RGB", 17: "grayscale", 18: "YCbCr", 20: "RGB"}
+
+// jp2Image reads the JP2 header box (ihdr, colr) or, for a bare
+// codestream, the SIZ marker segment.
+func jp2Image(b []byte, _ *source) imageInfo {
+	var im imageInfo
+	if hasPrefix(b, "\xFF\x4F\xFF\x51") {
+		if len(b) < 42 {
+			return im
+		}
+		im.width = peekBe(b[8:], 4) - peekBe(b[16:], 4)
+		im.height = peekBe(b[12:], 4) - peekBe(b[20:], 4)
+		im.depth = int(b[40]&0x7F) + 1
+		im.color = map[int]string{1: "grayscale", 3: "RGB", 4: "RGBA"}[peekBe(b[38:], 2)]
+		return im
+	}
+	bmffBoxes(b, func(typ string, p []byte) bool {
+		if typ != "jp2h" {
+			return true
+		}
+		channels := 0
+		bmffBoxes(p, func(typ string, p []byte) bool {
+			switch {
+			case typ == "ihdr" && len(p) >= 14:
+				im.height, im.width = peekBe(p, 4), peekBe(p[4:], 4)
+				channels = peekBe(p[8:], 2)
+				if p[10] != 0xFF {
+					im.depth = int(p[10]&0x7F) + 1
+				}
+			case typ == "colr" && len(p) >= 7 && p[0] == 1:
+				im.color = jp2ColorSpaces[peekBe(p[3:], 4)]
+			}
+			return true
+		})
+		if im.color == "RGB" && channels == 4 {
+			im.color = "RGBA"
+		}
+		return false
+	})
+	return im
+}
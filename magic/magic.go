@@ -0,0 +1,454 @@
+// Package magic identifies file types from their content, in the spirit of
+// libmagic and the unix file command, without cgo or an external database.
+package magic
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// MaxBytesToRead is the size of the leading buffer handed to every matcher
+// (ISO9660 magic starts at 0x8001).
+const MaxBytesToRead = 36 * 1024
+
+// Options tunes a single detection.
+type Options struct {
+	// Filename is an optional path hint. Some formats (glibc locale files)
+	// carry no magic and can only be recognised by where they live.
+	Filename string
+
+	// KeepGoing reports every matcher that accepts the input in
+	// Result.Candidates instead of stopping at the first, like file -k.
+	KeepGoing bool
+
+	// Rules are extra signatures, from LoadRules, tried alongside the
+	// built-in matchers.
+	Rules *Rules
+
+	// Decompress classifies the payload of gzip, bzip2, xz, zstd, lz4 and
+	// zlib streams, like file -z. The description becomes
+	// "Posix tar archive (gzip compressed data)" and the MIME type is the
+	// payload's.
+	Decompress bool
+
+	// Exif decodes the Exif block of JPEG, TIFF, HEIF and camera raw files
+	// and appends camera make and model, orientation and capture time to
+	// the description and to Result.Fields["exif"].
+	Exif bool
+
+	// Verbose appends schema summaries to the description: row and column
+	// counts of Parquet files, the writer schema and codec of Avro files,
+	// the field count of Arrow files and the HDF5 superblock version.
+	// Result.Fields carries them either way.
+	Verbose bool
+
+	depth int // compression layers and partition tables already looked through
+}
+
+// Result is the outcome of a detection.
+type Result struct {
+	Description string // human readable type, as printed by the CLI
+	MIME        string // MIME type, never empty
+	Matcher     string // name of the matcher that claimed the input
+
+	// Candidates lists every plausible type in matcher order, the primary
+	// match first. It is only filled in with Options.KeepGoing.
+	Candidates []Candidate
+
+	// Fields holds structured details some matchers extract, such as an ELF
+	// BuildID, keyed by snake_case name. It is nil for most types.
+	Fields map[string]any
+}
+
+// Candidate is one plausible type for an input.
+type Candidate struct {
+	Description string
+	MIME        string
+	Matcher     string
+	Confidence  int // 0-100, how specific the matcher's evidence is
+}
+
+// defaultConfidence is used by matchers that check a multi-byte signature or
+// structure and do not declare a confidence of their own.
+const defaultConfidence = 80
+
+type fileMatcher struct {
+	name       string
+	minLen     int
+	mime       string
+	confidence int // 0 means defaultConfidence
+	priority   int // Rule.Priority, ordering loaded rules among themselves
+	match      func([]byte, int, int, *source) bool
+	describe   func([]byte, int, int, *source) string
+	mimeOf     func([]byte, *source) string // optional, for matchers whose MIME varies
+	// details, when set, replaces describe for matchers that also fill in
+	// Result.Fields, so the input is parsed once.
+	details func([]byte, *source) (string, map[string]any)
+	// summary, when set, returns more detail for the description, shown
+	// only with Options.Verbose, and for Result.Fields.
+	summary func([]byte, *source) (string, map[string]any)
+	// eval, when set, stands in for match, describe and mimeOf in matchers
+	// that settle all three in one pass, such as loaded rules.
+	eval func([]byte, *source) (desc, mime string, ok bool)
+}
+
+// try reports whether m accepts the input. The matcher it returns describes
+// that input; for matchers with eval it carries the outcome, so the input
+// is evaluated once.
+func (m fileMatcher) try(b []byte, lenb int, magic int, file *source) (fileMatcher, bool) {
+	if m.eval == nil {
+		return m, m.match(b, lenb, magic, file)
+	}
+	desc, mime, ok := m.eval(b, file)
+	m.describe = func([]byte, int, int, *source) string { return desc }
+	m.mimeOf = func([]byte, *source) string { return mime }
+	return m, ok
+}
+
+// source is the random-access view of the input that matchers consult beyond
+// the leading buffer (trailers, archive directories). It is nil when only the
+// leading bytes are available.
+type source struct {
+	io.ReaderAt
+	size int64
+}
+
+// Detect identifies the content of r, which holds size bytes.
+func Detect(r io.ReaderAt, size int64, opts Options) (Result, error) {
+	n := size
+	if n > MaxBytesToRead {
+		n = MaxBytesToRead
+	}
+	contentByte := make([]byte, n)
+	numByte, err := r.ReadAt(contentByte, 0)
+	if err != nil && err != io.EOF {
+		return Result{}, err
+	}
+	return detectFromBytes(contentByte[:numByte], opts, &source{ReaderAt: r, size: size})
+}
+
+// DetectBytes identifies content of which only the leading bytes b are
+// known, such as the start of a pipe. Trailers and the total size are out
+// of reach, so matchers report what the leading bytes alone show.
+func DetectBytes(b []byte, opts Options) (Result, error) {
+	if len(b) > MaxBytesToRead {
+		b = b[:MaxBytesToRead]
+	}
+	return detectFromBytes(b, opts, nil)
+}
+
+// DetectFile opens filename and identifies its content. opts.Filename
+// defaults to filename.
+func DetectFile(filename string, opts Options) (Result, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return Result{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return Result{}, err
+	}
+	if opts.Filename == "" {
+		opts.Filename = filename
+	}
+	return Detect(file, info.Size(), opts)
+}
+
+func detectFromBytes(contentByte []byte, opts Options, file *source) (_ Result, err error) {
+	lenb := len(contentByte)
+	if lenb == 0 {
+		return Result{Description: "empty", MIME: "application/octet-stream"}, nil
+	}
+	magic := -1
+	if lenb > 112 {
+		magic = peekLe(contentByte[60:], 4)
+	}
+
+	// A parser tripping over hostile input costs this file, not the run.
+	current := ""
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s matcher failed on malformed input: %v", current, r)
+		}
+	}()
+
+	var res Result
+	for _, matcher := range opts.Rules.matcherList() {
+		current = matcher.name
+		if lenb < matcher.minLen {
+			continue
+		}
+		matcher, ok := matcher.try(contentByte, lenb, magic, file)
+		if !ok {
+			continue
+		}
+		// The data fallback says nothing new once another matcher has spoken.
+		if matcher.name == "data" && res.Matcher != "" {
+			continue
+		}
+		c, fields := describeMatch(matcher, contentByte, lenb, magic, opts, file)
+		if res.Matcher == "" {
+			res = Result{Description: c.Description, MIME: c.MIME, Matcher: c.Matcher, Fields: fields}
+		}
+		if !opts.KeepGoing {
+			break
+		}
+		res.Candidates = append(res.Candidates, c)
+	}
+	if res.Matcher == "" {
+		return Result{MIME: "application/octet-stream"}, nil
+	}
+	if opts.Decompress {
+		res = decompressedResult(res, contentByte, opts, file)
+	}
+	current = res.Matcher
+	res = partitionedResult(res, opts, contentByte, file)
+	if !opts.KeepGoing {
+		return res, nil
+	}
+	current = "zip"
+	if c, ok := appendedZipCandidate(file, res.Matcher); ok {
+		res.Candidates = append(res.Candidates, c)
+	}
+	return res, nil
+}
+
+func describeMatch(matcher fileMatcher, contentByte []byte, lenb int, magic int, opts Options, file *source) (Candidate, map[string]any) {
+	c := Candidate{Matcher: matcher.name, MIME: matcher.mime, Confidence: matcher.confidence}
+	if c.Confidence == 0 {
+		c.Confidence = defaultConfidence
+	}
+	if matcher.name == "data" {
+		if desc := glibcLocaleDescriptionForPath(opts.Filename, contentByte); desc != "" {
+			c.Description = desc
+			c.MIME = "application/octet-stream"
+			return c, nil
+		}
+	}
+	var fields map[string]any
+	if matcher.details != nil {
+		c.Description, fields = matcher.details(contentByte, file)
+	} else {
+		c.Description = matcher.describe(contentByte, lenb, magic, file)
+	}
+	if matcher.summary != nil {
+		more, f := matcher.summary(contentByte, file)
+		if opts.Verbose {
+			c.Description += more
+		}
+		if len(f) > 0 && fields == nil {
+			fields = map[string]any{}
+		}
+		for k, v := range f {
+			fields[k] = v
+		}
+	}
+	if read := exifReaders[matcher.name]; opts.Exif && read != nil {
+		if exif := read(contentByte, file); exif != nil {
+			c.Description += exif.String()
+			if fields == nil {
+				fields = map[string]any{}
+			}
+			fields["exif"] = exif.fields()
+		}
+	}
+	if c.MIME == "" && matcher.mimeOf != nil {
+		c.MIME = matcher.mimeOf(contentByte, file)
+	}
+	if c.MIME == "" {
+		c.MIME = dynamicMIME(c.Description)
+	}
+	return c, fields
+}
+
+func glibcLocaleDescriptionForPath(filename string, b []byte) string {
+	if filename == "" || isText(b) {
+		return ""
+	}
+	p := strings.ToLower(strings.ReplaceAll(filename, "\\", "/"))
+	if !strings.Contains(p, "/usr/lib/locale/") {
+		return ""
+	}
+
+	base := strings.ToLower(path.Base(p))
+	category := ""
+	switch {
+	case base == "sys_lc_messages":
+		category = "LC_MESSAGES"
+	case strings.HasPrefix(base, "lc_"):
+		candidate := strings.ToUpper(base)
+		if isKnownLocaleCategory(candidate) {
+			category = candidate
+		}
+	}
+	if category == "" {
+		return ""
+	}
+	return "glibc locale file " + category
+}
+
+func isKnownLocaleCategory(s string) bool {
+	switch s {
+	case "LC_ADDRESS", "LC_COLLATE", "LC_CTYPE", "LC_IDENTIFICATION", "LC_MEASUREMENT",
+		"LC_MESSAGES", "LC_MONETARY", "LC_NAME", "LC_NUMERIC", "LC_PAPER", "LC_TELEPHONE", "LC_TIME":
+		return true
+	default:
+		return false
+	}
+}
+
+// MIMEForDescription returns the MIME type fil associates with a description.
+// It covers descriptions that do not come from a matcher, such as the
+// "directory" and "symbolic link to ..." strings the CLI prints itself.
+func MIMEForDescription(desc string) string {
+	return dynamicMIME(desc)
+}
+
+// dynamicMIME resolves MIME types for matchers whose describe functions return
+// varying strings (zip sub-types, tar, ar, text, pem, 3gpp, xml) and for
+// OS-level descriptors (directory, symlink) that bypass the matcher registry.
+// All matchers with static descriptions use the mime field instead.
+func dynamicMIME(desc string) string {
+	dl := strings.ToLower(desc)
+	if mime := ooxmlMIME(dl); mime != "" {
+		return mime
+	}
+	if mime := cfbMIME(dl); mime != "" {
+		return mime
+	}
+	// OpenDocument types may be followed by ", with macros".
+	odf, _, _ := strings.Cut(dl, ",")
+
+	switch {
+	// OS-level types
+	case dl == "directory":
+		return "inode/directory"
+	case strings.HasPrefix(dl, "symbolic link to "):
+		return "inode/symlink"
+
+	// ZIP sub-types (doZip returns many different descriptions)
+	case strings.Contains(dl, "microsoft word 2007+"):
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	case strings.Contains(dl, "microsoft excel 2007+"):
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case strings.Contains(dl, "microsoft powerpoint 2007+"):
+		return "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+	case strings.Contains(dl, "microsoft ooxml"):
+		return "application/vnd.openxmlformats-officedocument"
+	case strings.Contains(dl, "microsoft silverlight application"):
+		return "application/x-silverlight-app"
+	case strings.Contains(dl, "adobe indesign idml package"):
+		return "application/vnd.adobe.indesign-idml-package"
+	case strings.Contains(dl, "android application package (apk)"):
+		return "application/vnd.android.package-archive"
+	case strings.Contains(dl, "android app bundle (aab)"):
+		return "application/vnd.android.appbundle"
+	case strings.Contains(dl, "kmz geospatial archive"):
+		return "application/vnd.google-earth.kmz"
+	case strings.Contains(dl, "apple ipsw firmware package"):
+		return "application/x-ipsw"
+	case strings.Contains(dl, "visual studio extension package (vsix)"):
+		return "application/vsix"
+	case strings.Contains(dl, "nuget package (nupkg)"):
+		return "application/vnd.nuget.package"
+	case strings.Contains(dl, "java war archive"), strings.Contains(dl, "java ear archive"), strings.Contains(dl, "java jar archive"):
+		return "application/java-archive"
+	case strings.Contains(dl, "epub document"):
+		return "application/epub+zip"
+	case odf == "opendocument text":
+		return "application/vnd.oasis.opendocument.text"
+	case odf == "opendocument text template":
+		return "application/vnd.oasis.opendocument.text-template"
+	case odf == "opendocument text web":
+		return "application/vnd.oasis.opendocument.text-web"
+	case odf == "opendocument text master":
+		return "application/vnd.oasis.opendocument.text-master"
+	case odf == "opendocument spreadsheet":
+		return "application/vnd.oasis.opendocument.spreadsheet"
+	case odf == "opendocument spreadsheet template":
+		return "application/vnd.oasis.opendocument.spreadsheet-template"
+	case odf == "opendocument presentation":
+		return "application/vnd.oasis.opendocument.presentation"
+	case odf == "opendocument presentation template":
+		return "application/vnd.oasis.opendocument.presentation-template"
+	case odf == "opendocument graphics":
+		return "application/vnd.oasis.opendocument.graphics"
+	case odf == "opendocument graphics template":
+		return "application/vnd.oasis.opendocument.graphics-template"
+	case odf == "opendocument chart":
+		return "application/vnd.oasis.opendocument.chart"
+	case odf == "opendocument chart template":
+		return "application/vnd.oasis.opendocument.chart-template"
+	case odf == "opendocument image":
+		return "application/vnd.oasis.opendocument.image"
+	case odf == "opendocument image template":
+		return "application/vnd.oasis.opendocument.image-template"
+	case odf == "opendocument formula":
+		return "application/vnd.oasis.opendocument.formula"
+	case odf == "opendocument formula template":
+		return "application/vnd.oasis.opendocument.formula-template"
+	case odf == "opendocument database":
+		return "application/vnd.oasis.opendocument.database"
+	case strings.HasPrefix(dl, "opendocument"):
+		return "application/vnd.oasis.opendocument"
+	case strings.HasPrefix(dl, "zip archive data"):
+		return "application/zip"
+
+	// AR sub-types (doAr)
+	case strings.Contains(dl, "debian binary package"):
+		return "application/vnd.debian.binary-package"
+	case dl == "ar archive":
+		return "application/x-archive"
+
+	// TAR sub-types (doTar)
+	case strings.Contains(dl, "vmware ova appliance"):
+		return "application/x-virtualbox-ova"
+	case strings.Contains(dl, "posix tar archive"):
+		return "application/x-tar"
+
+	// 3GPP variants
+	case strings.Contains(dl, "3gpp2 video file"):
+		return "video/3gpp2"
+	case strings.Contains(dl, "3gpp video file"):
+		return "video/3gpp"
+
+	// PGP variants
+	case strings.Contains(dl, "pgp public key"), strings.Contains(dl, "pgp private key"),
+		strings.Contains(dl, "pgp armored"), strings.Contains(dl, "pgp binary"):
+		return "application/pgp-keys"
+	case strings.Contains(dl, "pgp message"), strings.Contains(dl, "pgp signed message"):
+		return "application/pgp-encrypted"
+	case strings.Contains(dl, "pgp signature"):
+		return "application/pgp-signature"
+
+	// PEM variants — certificate request has a different MIME from all other PEM types
+	case strings.Contains(dl, "pem certificate request"):
+		return "application/pkcs10"
+	case strings.Contains(dl, "pem "), strings.Contains(dl, "openssh private key"):
+		return "application/x-pem-file"
+
+	// Text sub-types — check specific sub-types before the generic text prefix
+	case strings.Contains(dl, "apple mail message (emlx)"):
+		return "message/rfc822"
+	case strings.Contains(dl, "mbox mailbox"):
+		return "application/mbox"
+	case strings.Contains(dl, "markdown text"):
+		return "text/markdown"
+	case strings.Contains(dl, "openvpn config"):
+		return "application/x-openvpn-profile"
+	case strings.HasPrefix(dl, "ascii text"), strings.HasPrefix(dl, "utf-8 text"),
+		strings.HasPrefix(dl, "non-utf text"), strings.HasPrefix(dl, "unicode text, utf-16"):
+		return "text/plain"
+
+	// XML sub-type: VMware VMXF is text/plain, plain XML falls through to octet-stream
+	case strings.Contains(dl, "vmware supplemental configuration"):
+		return "text/plain"
+	}
+
+	return "application/octet-stream"
+}
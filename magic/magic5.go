@@ -0,0 +1,1080 @@
+package magic
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file implements the text form of libmagic's magic(5) database, so
+// existing magic files can be used without libmagic. Supported: direct,
+// relative (&N), negative and indirect ((N.t+M), &(...)) offsets; integer,
+// date, string, pstring, search and regex types with masks and flags;
+// default/clear; name/use subroutines; continuation levels; printf-style
+// messages with \b; and !:mime. Lines with other types are skipped and
+// reported through Rules.Warnings, as are string flags that are not
+// implemented, which are ignored.
+
+// LoadMagicSource parses a magic(5) source file. Its entries are tried, in
+// file order, before the built-in matchers; merged with other rules they
+// rank as priority 1.
+func LoadMagicSource(path string) (*Rules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseMagicSource(f, filepath.Base(path))
+}
+
+// ParseMagicSource parses magic(5) source text. name labels the matchers
+// ("name:line") and error messages.
+func ParseMagicSource(r io.Reader, name string) (*Rules, error) {
+	p := &magicParser{name: name, named: map[string]*magicEntry{}, skipFrom: -1}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		p.lineNo++
+		if err := p.parseLine(sc.Text()); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, p.lineNo, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	prog := &magicProgram{named: p.named}
+	var early []fileMatcher
+	for _, e := range p.top {
+		early = append(early, prog.matcher(fmt.Sprintf("%s:%d", name, e.line), e))
+	}
+	return newRules(len(p.top), early, nil, p.warnings), nil
+}
+
+type magicKind int
+
+const (
+	magicInt magicKind = iota
+	magicDate
+	magicString
+	magicPString
+	magicSearch
+	magicRegex
+	magicDefault
+	magicClear
+	magicName
+	magicUse
+)
+
+type magicEntry struct {
+	line     int
+	level    int
+	off      magicOffset
+	kind     magicKind
+	size     int  // integer width in bytes
+	big      bool // big-endian integer
+	signed   bool
+	local    bool   // ldate: print in local time
+	mask     uint64 // integer mask, 0 for none
+	flags    string // string/search/regex modifiers
+	rng      int    // search/regex window
+	op       byte   // = ! < > & ^ ~ x
+	num      uint64
+	str      []byte
+	re       *regexp.Regexp
+	message  string
+	mime     string
+	children []*magicEntry
+}
+
+type magicOffset struct {
+	relative bool  // &N: from the end of the parent's match
+	value    int64 // may be negative: from the end of the file
+
+	indirect    bool
+	indRelative bool // (&N...): the pointer itself is relative
+	indSize     int
+	indBig      bool
+	indID3      bool // .i/.I: the pointer is a sync-safe ID3 integer
+	indOp       byte
+	indArg      int64
+}
+
+type magicParser struct {
+	name     string
+	lineNo   int
+	top      []*magicEntry
+	named    map[string]*magicEntry
+	stack    []*magicEntry // last entry seen at each level
+	skipFrom int           // levels >= skipFrom belong to a skipped entry, -1 for none
+	last     *magicEntry
+	warnings []string
+}
+
+func (p *magicParser) parseLine(line string) error {
+	line = strings.TrimRight(line, "\r")
+	trimmed := strings.TrimLeft(line, " \t")
+	if trimmed == "" || trimmed[0] == '#' {
+		return nil
+	}
+	if strings.HasPrefix(trimmed, "!:") {
+		if p.last != nil && strings.HasPrefix(trimmed, "!:mime") {
+			p.last.mime = strings.TrimSpace(strings.TrimPrefix(trimmed, "!:mime"))
+		}
+		return nil
+	}
+
+	level := 0
+	for level < len(line) && line[level] == '>' {
+		level++
+	}
+	rest := line[level:]
+	if level > len(p.stack) {
+		return fmt.Errorf("continuation level %d without a parent", level)
+	}
+	p.stack = p.stack[:level]
+	if p.skipFrom >= 0 && level > p.skipFrom {
+		p.last = nil
+		return nil
+	}
+	p.skipFrom = -1
+
+	fields, message := splitMagicFields(rest)
+	if len(fields) < 2 {
+		return fmt.Errorf("expected offset and type")
+	}
+	e := &magicEntry{line: p.lineNo, level: level}
+
+	off, err := parseMagicOffset(fields[0])
+	if err != nil {
+		return err
+	}
+	e.off = off
+
+	supported, err := e.parseType(fields[1])
+	if err != nil {
+		return err
+	}
+	if !supported {
+		p.warnings = append(p.warnings, fmt.Sprintf("%s:%d: unsupported type %q, entry skipped", p.name, p.lineNo, fields[1]))
+		p.skipFrom = level
+		p.last = nil
+		// Keep the level structure so deeper lines are skipped, not
+		// re-parented.
+		p.stack = append(p.stack, nil)
+		return nil
+	}
+
+	if bad := e.unsupportedFlags(); bad != "" {
+		p.warnings = append(p.warnings, fmt.Sprintf("%s:%d: unsupported flags %q in %q, ignored", p.name, p.lineNo, bad, fields[1]))
+	}
+
+	test := "x"
+	if len(fields) > 2 {
+		test = fields[2]
+	}
+	if err := e.parseTest(test); err != nil {
+		return err
+	}
+	e.message = message
+
+	switch {
+	case e.kind == magicName:
+		if level != 0 {
+			return fmt.Errorf("name must be at level 0")
+		}
+		p.named[string(e.str)] = e
+	case level == 0:
+		p.top = append(p.top, e)
+	default:
+		parent := p.stack[level-1]
+		if parent == nil {
+			return fmt.Errorf("continuation of a skipped entry")
+		}
+		parent.children = append(parent.children, e)
+	}
+	p.stack = append(p.stack, e)
+	p.last = e
+	return nil
+}
+
+// splitMagicFields returns up to three whitespace separated fields (offset,
+// type, test) and the remaining message. Backslash escapes whitespace.
+func splitMagicFields(s string) ([]string, string) {
+	var fields []string
+	i := 0
+	for len(fields) < 3 {
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+		if i >= len(s) {
+			return fields, ""
+		}
+		start := i
+		for i < len(s) && s[i] != ' ' && s[i] != '\t' {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+			}
+			i++
+		}
+		fields = append(fields, s[start:i])
+	}
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	return fields, s[i:]
+}
+
+func parseMagicOffset(s string) (magicOffset, error) {
+	var off magicOffset
+	if strings.HasPrefix(s, "&") {
+		off.relative = true
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "(") {
+		v, err := parseMagicInt(s)
+		if err != nil {
+			return off, fmt.Errorf("bad offset %q", s)
+		}
+		off.value = v
+		return off, nil
+	}
+
+	end := strings.LastIndexByte(s, ')')
+	if end < 0 {
+		return off, fmt.Errorf("unterminated indirect offset %q", s)
+	}
+	inner := s[1:end]
+	off.indirect = true
+	off.indSize, off.indBig = 4, false
+	if strings.HasPrefix(inner, "&") {
+		off.indRelative = true
+		inner = inner[1:]
+	}
+
+	// Split "base[.type][op arg]".
+	j := 0
+	if j < len(inner) && (inner[j] == '-' || inner[j] == '+') {
+		j++
+	}
+	for j < len(inner) && strings.IndexByte("0123456789abcdefABCDEFxX", inner[j]) >= 0 {
+		// Stop at the type separator or an operator.
+		j++
+	}
+	base, err := parseMagicInt(inner[:j])
+	if err != nil {
+		return off, fmt.Errorf("bad indirect offset %q", s)
+	}
+	off.value = base
+	inner = inner[j:]
+	if strings.HasPrefix(inner, ".") || strings.HasPrefix(inner, ",") {
+		if len(inner) < 2 {
+			return off, fmt.Errorf("bad indirect type in %q", s)
+		}
+		switch inner[1] {
+		case 'b', 'B', 'c', 'C':
+			off.indSize = 1
+		case 's', 'h':
+			off.indSize = 2
+		case 'S', 'H':
+			off.indSize, off.indBig = 2, true
+		case 'l':
+			off.indSize = 4
+		case 'L':
+			off.indSize, off.indBig = 4, true
+		case 'q':
+			off.indSize = 8
+		case 'Q':
+			off.indSize, off.indBig = 8, true
+		case 'i':
+			off.indSize, off.indID3 = 4, true
+		case 'I':
+			off.indSize, off.indBig, off.indID3 = 4, true, true
+		default:
+			return off, fmt.Errorf("unsupported indirect type %q", inner[1])
+		}
+		inner = inner[2:]
+	}
+	if inner != "" {
+		op := inner[0]
+		if strings.IndexByte("+-*/%&|^", op) < 0 {
+			return off, fmt.Errorf("bad indirect operator in %q", s)
+		}
+		arg, err := parseMagicInt(inner[1:])
+		if err != nil {
+			return off, fmt.Errorf("bad indirect operand in %q", s)
+		}
+		off.indOp, off.indArg = op, arg
+	}
+	return off, nil
+}
+
+func parseMagicInt(s string) (int64, error) {
+	s = strings.TrimRight(s, "LlUu")
+	neg := false
+	if strings.HasPrefix(s, "-") {
+		neg = true
+		s = s[1:]
+	} else if strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+	if s == "" {
+		return 0, fmt.Errorf("empty number")
+	}
+	v, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return 0, err
+	}
+	if neg {
+		return -int64(v), nil
+	}
+	return int64(v), nil
+}
+
+// parseType fills in the type fields of e. It reports false for valid but
+// unsupported types.
+func (e *magicEntry) parseType(t string) (bool, error) {
+	base := t
+	if i := strings.IndexAny(t, "&/"); i >= 0 {
+		base = t[:i]
+	}
+	suffix := t[len(base):]
+
+	unsigned := strings.HasPrefix(base, "u") && base != "use"
+	if unsigned {
+		base = base[1:]
+	}
+
+	e.kind = magicInt
+	e.signed = !unsigned
+	switch base {
+	case "byte":
+		e.size = 1
+	case "short", "leshort":
+		e.size = 2
+	case "beshort":
+		e.size, e.big = 2, true
+	case "long", "lelong":
+		e.size = 4
+	case "belong":
+		e.size, e.big = 4, true
+	case "quad", "lequad":
+		e.size = 8
+	case "bequad":
+		e.size, e.big = 8, true
+	case "date", "ledate", "ldate", "leldate":
+		e.kind, e.size = magicDate, 4
+		e.local = strings.Contains(base, "ldate")
+	case "bedate", "beldate":
+		e.kind, e.size, e.big = magicDate, 4, true
+		e.local = base == "beldate"
+	case "qdate", "leqdate":
+		e.kind, e.size = magicDate, 8
+	case "beqdate":
+		e.kind, e.size, e.big = magicDate, 8, true
+	case "string":
+		e.kind = magicString
+	case "pstring":
+		e.kind = magicPString
+	case "search":
+		e.kind = magicSearch
+	case "regex":
+		e.kind = magicRegex
+	case "default":
+		e.kind = magicDefault
+	case "clear":
+		e.kind = magicClear
+	case "name":
+		e.kind = magicName
+	case "use":
+		e.kind = magicUse
+	default:
+		return false, nil
+	}
+	if unsigned && e.kind != magicInt && e.kind != magicDate {
+		return false, fmt.Errorf("bad type %q", t)
+	}
+
+	switch e.kind {
+	case magicInt, magicDate:
+		if suffix == "" {
+			return true, nil
+		}
+		if suffix[0] != '&' {
+			return false, nil
+		}
+		m, err := parseMagicInt(suffix[1:])
+		if err != nil {
+			return false, fmt.Errorf("bad mask in %q", t)
+		}
+		e.mask = uint64(m)
+	case magicString, magicPString, magicSearch, magicRegex:
+		for _, part := range strings.Split(strings.TrimPrefix(suffix, "/"), "/") {
+			if part == "" {
+				continue
+			}
+			if part[0] >= '0' && part[0] <= '9' {
+				// A window may carry trailing flags, e.g. regex/100l.
+				k := 0
+				for k < len(part) && (part[k] >= '0' && part[k] <= '9' || part[k] == 'x' || part[k] >= 'a' && part[k] <= 'f' && strings.HasPrefix(part, "0x")) {
+					k++
+				}
+				n, err := parseMagicInt(part[:k])
+				if err != nil {
+					return false, fmt.Errorf("bad range in %q", t)
+				}
+				e.rng = int(n)
+				e.flags += part[k:]
+				continue
+			}
+			e.flags += part
+		}
+	default:
+		if suffix != "" {
+			return false, fmt.Errorf("bad type %q", t)
+		}
+	}
+	return true, nil
+}
+
+func (e *magicEntry) parseTest(t string) error {
+	switch e.kind {
+	case magicDefault, magicClear:
+		e.op = 'x'
+		return nil
+	case magicName, magicUse:
+		e.str = []byte(t)
+		return nil
+	}
+
+	op := byte('=')
+	if t == "x" {
+		e.op = 'x'
+		return nil
+	}
+	if len(t) > 0 && strings.IndexByte("=!<>&^~", t[0]) >= 0 {
+		op = t[0]
+		t = t[1:]
+	}
+	e.op = op
+
+	switch e.kind {
+	case magicInt, magicDate:
+		if e.op == 'x' {
+			return nil
+		}
+		v, err := parseMagicInt(t)
+		if err != nil {
+			return fmt.Errorf("bad value %q", t)
+		}
+		e.num = uint64(v)
+	case magicRegex:
+		expr := string(unescapeMagic(t))
+		if strings.Contains(e.flags, "c") {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile("(?m)" + expr)
+		if err != nil {
+			return fmt.Errorf("bad regex %q: %v", t, err)
+		}
+		e.re = re
+	default:
+		e.str = unescapeMagic(t)
+	}
+	return nil
+}
+
+// unescapeMagic decodes the C-style escapes magic(5) allows in strings.
+func unescapeMagic(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 == len(s) {
+			out = append(out, c)
+			continue
+		}
+		i++
+		switch c = s[i]; c {
+		case 'n':
+			out = append(out, '\n')
+		case 't':
+			out = append(out, '\t')
+		case 'r':
+			out = append(out, '\r')
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case 'v':
+			out = append(out, '\v')
+		case 'a':
+			out = append(out, '\a')
+		case 'x':
+			v, n := 0, 0
+			for n < 2 && i+1 < len(s) && isHexDigit(s[i+1]) {
+				i++
+				v = v*16 + hexDigit(s[i])
+				n++
+			}
+			if n == 0 {
+				out = append(out, 'x')
+				continue
+			}
+			out = append(out, byte(v))
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			v, n := int(c-'0'), 1
+			for n < 3 && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '7' {
+				i++
+				v = v*8 + int(s[i]-'0')
+				n++
+			}
+			out = append(out, byte(v))
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func hexDigit(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'f':
+		return int(c-'a') + 10
+	default:
+		return int(c-'A') + 10
+	}
+}
+
+// magicProgram evaluates parsed entries; named holds the name/use targets.
+type magicProgram struct {
+	named map[string]*magicEntry
+}
+
+type magicEval struct {
+	prog  *magicProgram
+	b     []byte
+	file  *source
+	desc  strings.Builder
+	mime  string
+	depth int
+}
+
+func (prog *magicProgram) matcher(name string, e *magicEntry) fileMatcher {
+	return fileMatcher{
+		name:     name,
+		priority: 1,
+		eval: func(b []byte, file *source) (string, string, bool) {
+			ev := &magicEval{prog: prog, b: b, file: file}
+			if !ev.entry(e, 0, 0, false) {
+				return "", "", false
+			}
+			desc := strings.TrimSpace(ev.desc.String())
+			return desc, ev.mime, desc != ""
+		},
+	}
+}
+
+// entry tests e and, when it matches, prints its message and evaluates its
+// children. base is added to absolute offsets (inside a "use"); parentEnd is
+// where the parent's match ended, for relative offsets.
+func (ev *magicEval) entry(e *magicEntry, base, parentEnd int64, swap bool) bool {
+	ok, end, val := ev.test(e, base, parentEnd, swap)
+	if !ok {
+		return false
+	}
+	if e.kind == magicUse {
+		target := string(e.str)
+		flip := false
+		if strings.HasPrefix(target, "\\^") || strings.HasPrefix(target, "^") {
+			target = strings.TrimLeft(target, "\\^")
+			flip = true
+		}
+		if named := ev.prog.named[target]; named != nil && ev.depth < 16 {
+			off, ok := ev.offset(e.off, base, parentEnd, swap)
+			if !ok {
+				return false
+			}
+			ev.depth++
+			ev.children(named.children, off, off, swap != flip)
+			ev.depth--
+		}
+		return true
+	}
+	ev.print(e, val)
+	ev.children(e.children, base, end, swap)
+	return true
+}
+
+func (ev *magicEval) children(entries []*magicEntry, base, parentEnd int64, swap bool) {
+	matched := false
+	for _, c := range entries {
+		switch c.kind {
+		case magicClear:
+			matched = false
+			continue
+		case magicDefault:
+			if matched {
+				continue
+			}
+		}
+		if ev.entry(c, base, parentEnd, swap) {
+			matched = true
+		}
+	}
+}
+
+func (ev *magicEval) print(e *magicEntry, val any) {
+	if e.mime != "" && ev.mime == "" {
+		ev.mime = e.mime
+	}
+	if e.message == "" {
+		return
+	}
+	msg := formatMagicMessage(e.message, val)
+	if strings.HasPrefix(msg, "\\b") {
+		msg = msg[2:]
+	} else if strings.HasPrefix(msg, "\b") {
+		msg = msg[1:]
+	} else if ev.desc.Len() > 0 {
+		ev.desc.WriteByte(' ')
+	}
+	ev.desc.WriteString(msg)
+}
+
+// offset resolves o to an absolute file offset.
+func (ev *magicEval) offset(o magicOffset, base, parentEnd int64, swap bool) (int64, bool) {
+	off := o.value
+	if !o.indirect {
+		if o.relative {
+			return addOffset(parentEnd, off)
+		}
+		if off < 0 {
+			return addOffset(ev.size(), off)
+		}
+		return addOffset(base, off)
+	}
+
+	var ptr int64
+	var ok bool
+	switch {
+	case o.indRelative:
+		ptr, ok = addOffset(parentEnd, off)
+	case off < 0:
+		ptr, ok = addOffset(ev.size(), off)
+	default:
+		ptr, ok = addOffset(base, off)
+	}
+	if !ok {
+		return 0, false
+	}
+	data, ok := readRegion(ev.b, ev.file, ptr, o.indSize)
+	if !ok {
+		return 0, false
+	}
+	big := o.indBig != swap
+	var v int64
+	if big {
+		v = int64(peekBe(data, o.indSize))
+	} else {
+		v = int64(peekLe(data, o.indSize))
+	}
+	if o.indID3 {
+		// Sync-safe integer: 7 bits per byte.
+		d := data
+		if !big {
+			d = []byte{data[3], data[2], data[1], data[0]}
+		}
+		v = int64(d[0]&0x7f)<<21 | int64(d[1]&0x7f)<<14 | int64(d[2]&0x7f)<<7 | int64(d[3]&0x7f)
+	}
+	// The pointer comes from the input, so the arithmetic on it must not
+	// wrap into a plausible offset.
+	switch o.indOp {
+	case '+':
+		v, ok = addOffset(v, o.indArg)
+	case '-':
+		if o.indArg == math.MinInt64 {
+			return 0, false
+		}
+		v, ok = addOffset(v, -o.indArg)
+	case '*':
+		if o.indArg != 0 && (v*o.indArg)/o.indArg != v {
+			return 0, false
+		}
+		v *= o.indArg
+	case '/':
+		if o.indArg == 0 {
+			return 0, false
+		}
+		v /= o.indArg
+	case '%':
+		if o.indArg == 0 {
+			return 0, false
+		}
+		v %= o.indArg
+	case '&':
+		v &= o.indArg
+	case '|':
+		v |= o.indArg
+	case '^':
+		v ^= o.indArg
+	}
+	if !ok {
+		return 0, false
+	}
+	if o.relative {
+		return addOffset(parentEnd, v)
+	}
+	return addOffset(base, v)
+}
+
+// addOffset adds two offsets, failing where int64 would wrap.
+func addOffset(a, b int64) (int64, bool) {
+	sum := a + b
+	if b > 0 && sum < a || b < 0 && sum > a {
+		return 0, false
+	}
+	return sum, true
+}
+
+func (ev *magicEval) size() int64 {
+	return inputSize(ev.b, ev.file)
+}
+
+// test evaluates e alone. It returns whether it holds, where the matched
+// data ends and the value to print.
+func (ev *magicEval) test(e *magicEntry, base, parentEnd int64, swap bool) (bool, int64, any) {
+	if e.kind == magicDefault {
+		return true, parentEnd, nil
+	}
+	if e.kind == magicUse {
+		return true, parentEnd, nil
+	}
+	off, ok := ev.offset(e.off, base, parentEnd, swap)
+	if !ok || off < 0 {
+		return false, 0, nil
+	}
+
+	switch e.kind {
+	case magicInt, magicDate:
+		data, ok := readRegion(ev.b, ev.file, off, e.size)
+		if !ok {
+			return false, 0, nil
+		}
+		big := e.big != swap
+		var u uint64
+		if big {
+			u = uint64(peekBe(data, e.size))
+		} else {
+			u = uint64(peekLe(data, e.size))
+		}
+		if e.mask != 0 {
+			u &= e.mask
+		}
+		end := off + int64(e.size)
+		if !compareMagicInt(e, u) {
+			return false, 0, nil
+		}
+		if e.kind == magicDate {
+			t := time.Unix(int64(u), 0)
+			if !e.local {
+				t = t.UTC()
+			}
+			return true, end, t.Format("Mon Jan _2 15:04:05 2006")
+		}
+		if e.signed {
+			return true, end, signExtend(u, e.size)
+		}
+		return true, end, u
+
+	case magicString:
+		return ev.testString(e, off)
+
+	case magicPString:
+		lenSize, big, includesSelf := 1, false, false
+		for _, f := range e.flags {
+			switch f {
+			case 'B':
+				lenSize = 1
+			case 'H':
+				lenSize, big = 2, true
+			case 'h':
+				lenSize = 2
+			case 'L':
+				lenSize, big = 4, true
+			case 'l':
+				lenSize = 4
+			case 'J':
+				includesSelf = true
+			}
+		}
+		data, ok := readRegion(ev.b, ev.file, off, lenSize)
+		if !ok {
+			return false, 0, nil
+		}
+		n := peekLe(data, lenSize)
+		if big {
+			n = peekBe(data, lenSize)
+		}
+		if includesSelf {
+			n -= lenSize
+		}
+		if n < 0 || n > 1<<16 {
+			return false, 0, nil
+		}
+		str, ok := readRegion(ev.b, ev.file, off+int64(lenSize), n)
+		if !ok {
+			return false, 0, nil
+		}
+		if !compareMagicString(e, str) {
+			return false, 0, nil
+		}
+		return true, off + int64(lenSize+n), string(str)
+
+	case magicSearch:
+		window := e.rng
+		if window <= 0 {
+			window = 8192
+		}
+		data, ok := readRegion(ev.b, ev.file, off, window+len(e.str))
+		if !ok {
+			if data, ok = readRegion(ev.b, ev.file, off, -1); !ok {
+				return false, 0, nil
+			}
+		}
+		i := indexMagicString(data, e.str, e.flags)
+		if i < 0 || i > window {
+			return false, 0, nil
+		}
+		return true, off + int64(i+len(e.str)), string(data[i : i+len(e.str)])
+
+	case magicRegex:
+		window := e.rng
+		if window <= 0 {
+			window = 8192
+		}
+		data, ok := readRegion(ev.b, ev.file, off, -1)
+		if !ok {
+			return false, 0, nil
+		}
+		if strings.Contains(e.flags, "l") {
+			// The window counts lines rather than bytes.
+			lines := 0
+			for i, c := range data {
+				if c == '\n' {
+					lines++
+					if lines == window {
+						data = data[:i+1]
+						break
+					}
+				}
+			}
+		} else if len(data) > window {
+			data = data[:window]
+		}
+		loc := e.re.FindIndex(data)
+		if loc == nil {
+			return false, 0, nil
+		}
+		end := off + int64(loc[1])
+		if strings.Contains(e.flags, "s") {
+			end = off + int64(loc[0])
+		}
+		return true, end, string(data[loc[0]:loc[1]])
+	}
+	return false, 0, nil
+}
+
+func (ev *magicEval) testString(e *magicEntry, off int64) (bool, int64, any) {
+	if e.op == 'x' || e.op == '>' || e.op == '<' || e.op == '!' {
+		// Compare against, and print, the C string found at off.
+		data, ok := readRegion(ev.b, ev.file, off, -1)
+		if !ok {
+			return false, 0, nil
+		}
+		n := 0
+		for n < len(data) && n < 256 && data[n] != 0 && data[n] != '\n' && data[n] != '\r' {
+			n++
+		}
+		str := data[:n]
+		// Like libmagic, compare only as many bytes as the pattern has.
+		if e.op != 'x' && !compareMagicString(e, data[:min(len(data), len(e.str))]) {
+			return false, 0, nil
+		}
+		return true, off + int64(n), string(str)
+	}
+	data, ok := readRegion(ev.b, ev.file, off, len(e.str))
+	if !ok || indexMagicString(data, e.str, e.flags) != 0 {
+		return false, 0, nil
+	}
+	return true, off + int64(len(e.str)), string(data)
+}
+
+// magicFlags are the string modifiers each type implements.
+var magicFlags = map[magicKind]string{
+	magicString:  "cC",
+	magicSearch:  "cC",
+	magicRegex:   "cls",
+	magicPString: "BHhLlJ",
+}
+
+// unsupportedFlags returns the modifiers of e that evaluation ignores.
+func (e *magicEntry) unsupportedFlags() string {
+	var bad []rune
+	for _, f := range e.flags {
+		if !strings.ContainsRune(magicFlags[e.kind], f) {
+			bad = append(bad, f)
+		}
+	}
+	return string(bad)
+}
+
+func compareMagicInt(e *magicEntry, u uint64) bool {
+	// u is read zero-extended; the value was parsed as 64 bits, so -1
+	// must be narrowed to the type to match 0xFF, 0xFFFF and so on.
+	want := e.num & sizeMask(e.size)
+	switch e.op {
+	case 'x':
+		return true
+	case '=':
+		return u == want
+	case '!':
+		return u != want
+	case '&':
+		return u&want == want
+	case '^':
+		return u&want == 0
+	case '~':
+		return u == ^want&sizeMask(e.size)
+	case '<', '>':
+		if e.signed {
+			a, b := signExtend(u, e.size), signExtend(want, e.size)
+			if e.op == '<' {
+				return a < b
+			}
+			return a > b
+		}
+		if e.op == '<' {
+			return u < want
+		}
+		return u > want
+	}
+	return false
+}
+
+func sizeMask(size int) uint64 {
+	if size >= 8 {
+		return ^uint64(0)
+	}
+	return 1<<(8*uint(size)) - 1
+}
+
+func signExtend(u uint64, size int) int64 {
+	shift := 64 - 8*uint(size)
+	return int64(u<<shift) >> shift
+}
+
+func compareMagicString(e *magicEntry, str []byte) bool {
+	switch e.op {
+	case 'x':
+		return true
+	case '!':
+		return !bytes.Equal(str, e.str)
+	case '>', '<':
+		c := bytes.Compare(str, e.str)
+		if e.op == '>' {
+			return c > 0
+		}
+		return c < 0
+	default:
+		return bytes.Equal(str, e.str)
+	}
+}
+
+// indexMagicString finds pat in data honouring the c (lower-case letters in
+// pat match both cases) and C (upper-case likewise) flags.
+func indexMagicString(data, pat []byte, flags string) int {
+	lower := strings.Contains(flags, "c")
+	upper := strings.Contains(flags, "C")
+	if !lower && !upper {
+		return bytes.Index(data, pat)
+	}
+	for i := 0; i+len(pat) <= len(data); i++ {
+		ok := true
+		for j, p := range pat {
+			d := data[i+j]
+			switch {
+			case lower && p >= 'a' && p <= 'z':
+				ok = d == p || d == p-'a'+'A'
+			case upper && p >= 'A' && p <= 'Z':
+				ok = d == p || d == p-'A'+'a'
+			default:
+				ok = d == p
+			}
+			if !ok {
+				break
+			}
+		}
+		if ok {
+			return i
+		}
+	}
+	return -1
+}
+
+// formatMagicMessage expands the single printf directive a magic message may
+// hold, translating C length modifiers and %u/%i to Go.
+func formatMagicMessage(msg string, val any) string {
+	i := strings.IndexByte(msg, '%')
+	for i >= 0 && i+1 < len(msg) && msg[i+1] == '%' {
+		next := strings.IndexByte(msg[i+2:], '%')
+		if next < 0 {
+			i = -1
+			break
+		}
+		i += 2 + next
+	}
+	if i < 0 || val == nil {
+		return strings.ReplaceAll(msg, "%%", "%")
+	}
+
+	j := i + 1
+	for j < len(msg) && strings.IndexByte("-+ #0123456789.", msg[j]) >= 0 {
+		j++
+	}
+	flags := msg[i+1 : j]
+	for j < len(msg) && strings.IndexByte("hlqjzt", msg[j]) >= 0 {
+		j++
+	}
+	if j >= len(msg) {
+		return msg
+	}
+	verb := msg[j]
+	switch verb {
+	case 'u', 'i':
+		verb = 'd'
+	}
+	if _, isString := val.(string); isString {
+		verb = 's'
+	} else if verb == 's' {
+		verb = 'd'
+	}
+	if v, ok := val.(uint64); ok && verb == 'c' {
+		val = rune(v)
+	}
+	expanded := fmt.Sprintf("%"+flags+string(verb), val)
+	return strings.ReplaceAll(msg[:i], "%%", "%") + expanded + strings.ReplaceAll(msg[j+1:], "%%", "%")
+}
@@ -1,4 +1,4 @@
-package main
+package magic
 
 var matchers = []fileMatcher{
 	matcherElf,
@@ -154,10 +154,8 @@ var matchers = []fileMatcher{
 	matcherPsd,
 	matcherAvi,
 	matcherAsf,
-	matcherMsi,
 	matcherOutlookStore,
 	matcherMsAccess,
-	matcherMsg,
 	matcherOle,
 	matcherWebp,
 	matcherRtf,
@@ -0,0 +1,1337 @@
+package magic
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var matcherAr = fileMatcher{
+	name:   "ar",
+	minLen: 8,
+	mime:   "", // dynamic: "ar archive" or "Debian binary package …"
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 8 && hasPrefix(b, "!<arch>\n")
+	},
+	details: func(b []byte, file *source) (string, map[string]any) {
+		return doAr(file)
+	},
+}
+
+var matcherRpm = fileMatcher{
+	name:   "rpm",
+	minLen: 96,
+	mime:   "application/x-rpm",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		if lenb < 96 || !hasPrefix(b, "\xED\xAB\xEE\xDB") {
+			return false
+		}
+		major := b[4]
+		return major == 3 || major == 4
+	},
+	details: doRpm,
+}
+
+var matcherTar = fileMatcher{
+	name:   "tar",
+	minLen: 501,
+	mime:   "", // dynamic: "Posix tar archive" or "VMware OVA appliance"
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 500 && equal(b[257:262], "ustar")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return doTar(file)
+	},
+}
+
+var matcherZip = fileMatcher{
+	name:   "zip",
+	minLen: 6,
+	mime:   "", // dynamic: many sub-types (docx, apk, epub, etc.)
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 5 && hasPrefix(b, "PK\x03\x04")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return doZip(file)
+	},
+}
+
+var matcherDmg = fileMatcher{
+	name:   "dmg",
+	minLen: 1,
+	mime:   "application/x-apple-diskimage",
+	match: func(b []byte, lenb int, magic int, file *source) bool {
+		return hasDmgTrailer(file)
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "Apple UDIF disk image"
+	},
+}
+
+var matcherEwf = fileMatcher{
+	name:   "ewf",
+	minLen: 8,
+	mime:   "application/x-ewf",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 8 && (hasPrefix(b, "EVF\x09\x0D\x0A\xFF\x00") || hasPrefix(b, "LVF\x09\x0D\x0A\xFF\x00"))
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "Expert Witness Compression Format (EWF) image"
+	},
+}
+
+var matcherVmdk = fileMatcher{
+	name:   "vmdk",
+	minLen: 4,
+	mime:   "application/x-vmdk",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && hasPrefix(b, "KDMV")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "VMware virtual disk"
+	},
+}
+
+var matcherVmwareNvram = fileMatcher{
+	name:   "vmware-nvram",
+	minLen: 32,
+	mime:   "application/octet-stream",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return looksLikeVMwareNvram(b)
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "VMware NVRAM file"
+	},
+}
+
+var matcherQcow = fileMatcher{
+	name:   "qcow",
+	minLen: 4,
+	mime:   "application/octet-stream",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && hasPrefix(b, "QFI\xfb")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "QEMU QCOW disk image"
+	},
+}
+
+func looksLikeVMwareNvram(b []byte) bool {
+	if looksLikeVMwareNvramMRVN(b) {
+		return true
+	}
+	return looksLikeVMwareNvramUEFI(b)
+}
+
+func looksLikeVMwareNvramUEFI(b []byte) bool {
+	// VMware .nvram files commonly store a UEFI firmware volume header at byte 0.
+	// EFI_FIRMWARE_VOLUME_HEADER signature "_FVH" sits at offset 0x28.
+	if len(b) < 64 || !equal(b[40:44], "_FVH") {
+		return false
+	}
+
+	// UEFI firmware volume header starts with a 16-byte zero vector.
+	for i := 0; i < 16; i++ {
+		if b[i] != 0x00 {
+			return false
+		}
+	}
+
+	// Header length and revision sanity checks help reduce false positives.
+	headerLen := peekLe(b[52:54], 2)
+	if headerLen < 56 || headerLen > len(b) {
+		return false
+	}
+
+	revision := b[55]
+	return revision == 1 || revision == 2
+}
+
+func looksLikeVMwareNvramMRVN(b []byte) bool {
+	// Legacy VMware NVRAM often starts with "MRVN" and then CMOS-tagged records.
+	if len(b) < 32 || !hasPrefix(b, "MRVN") {
+		return false
+	}
+
+	// Version field is usually a small LE integer (often 1).
+	version := peekLe(b[4:8], 4)
+	if version <= 0 || version > 16 {
+		return false
+	}
+
+	end := len(b)
+	if end > 512 {
+		end = 512
+	}
+
+	// Require at least one CMOS marker near the header.
+	return bytes.Contains(b[8:end], []byte("CMOS"))
+}
+
+var matcherVhdx = fileMatcher{
+	name:   "vhdx",
+	minLen: 8,
+	mime:   "application/octet-stream",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 8 && hasPrefix(b, "vhdxfile")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "Microsoft VHDX disk image"
+	},
+}
+
+var matcherVdi = fileMatcher{
+	name:   "vdi",
+	minLen: len("<<< Oracle VM VirtualBox Disk Image >>>"),
+	mime:   "application/octet-stream",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= len("<<< Oracle VM VirtualBox Disk Image >>>") &&
+			hasPrefix(b, "<<< Oracle VM VirtualBox Disk Image >>>")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "VirtualBox VDI disk image"
+	},
+}
+
+var matcherBzip2 = fileMatcher{
+	name:   "bzip2",
+	minLen: 5,
+	mime:   "application/x-bzip2",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 4 && hasPrefix(b, "BZh")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "bzip2 compressed data"
+	},
+}
+
+var matcherXz = fileMatcher{
+	name:   "xz",
+	minLen: 6,
+	mime:   "application/x-xz",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 6 && hasPrefix(b, "\xFD7zXZ\x00")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "XZ compressed data"
+	},
+}
+
+var matcherZstd = fileMatcher{
+	name:   "zstd",
+	minLen: 4,
+	mime:   "application/zstd",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && hasPrefix(b, "\x28\xB5\x2F\xFD")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "Zstandard compressed data"
+	},
+}
+
+var matcherLz4 = fileMatcher{
+	name:   "lz4",
+	minLen: 4,
+	mime:   "application/x-lz4",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && hasPrefix(b, "\x04\x22\x4D\x18")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "LZ4 compressed data"
+	},
+}
+
+var matcherLzip = fileMatcher{
+	name:   "lzip",
+	minLen: 4,
+	mime:   "application/x-lzip",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && hasPrefix(b, "LZIP")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "LZIP compressed data"
+	},
+}
+
+var matcherGzip = fileMatcher{
+	name:   "gzip",
+	minLen: 11,
+	mime:   "application/gzip",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 10 && hasPrefix(b, "\x1f\x8b")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return doGzip(b, file)
+	},
+}
+
+var matcherSzdd = fileMatcher{
+	name:   "szdd",
+	minLen: 8,
+	mime:   "application/x-ms-compress",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 8 && hasPrefix(b, "SZDD")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "MS Compress archive data, SZDD variant"
+	},
+}
+
+var matcherRar = fileMatcher{
+	name:   "rar",
+	minLen: 7,
+	mime:   "application/vnd.rar",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return (lenb >= 8 && hasPrefix(b, "\x52\x61\x72\x21\x1A\x07\x01\x00")) ||
+			(lenb >= 7 && hasPrefix(b, "\x52\x61\x72\x21\x1A\x07\x00"))
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "RAR archive data"
+	},
+}
+
+var matcher7zip = fileMatcher{
+	name:   "7zip",
+	minLen: 17,
+	mime:   "application/x-7z-compressed",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 16 && hasPrefix(b, "\x37\x7A\xBC\xAF\x27\x1C")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "7zip archive data"
+	},
+}
+
+var matcherArj = fileMatcher{
+	name:   "arj",
+	minLen: 11,
+	mime:   "application/x-arj",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		if lenb < 11 || !hasPrefix(b, "\x60\xEA") {
+			return false
+		}
+		// Sanity-check the basic header size field (bytes 2–3 LE) to reduce false positives.
+		headerSize := int(b[2]) | int(b[3])<<8
+		return headerSize >= 26 && headerSize <= 2600
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "ARJ archive"
+	},
+}
+
+var matcherCpio = fileMatcher{
+	name:       "cpio",
+	minLen:     6,
+	mime:       "application/x-cpio",
+	confidence: 40, // binary cpio magic is 2 bytes
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		if lenb >= 6 && (hasPrefix(b, "070701") || hasPrefix(b, "070702") || hasPrefix(b, "070707")) {
+			return true
+		}
+		return lenb >= 2 && (hasPrefix(b, "\xC7\x71") || hasPrefix(b, "\x71\xC7"))
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		switch {
+		case hasPrefix(b, "070701"):
+			return "CPIO archive (SVR4 no CRC)"
+		case hasPrefix(b, "070702"):
+			return "CPIO archive (SVR4 with CRC)"
+		case hasPrefix(b, "070707"):
+			return "CPIO archive (portable ASCII)"
+		default:
+			return "CPIO archive"
+		}
+	},
+}
+
+var matcherLzh = fileMatcher{
+	name:       "lzh",
+	minLen:     7,
+	mime:       "application/x-lzh-compressed",
+	confidence: 40, // 3-byte method id at offset 2
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		// LHA/LZH header: [size][checksum]-lhX- where X is 0-9 or a-e
+		return lenb >= 7 && b[2] == '-' && b[3] == 'l' && b[4] == 'h' && b[6] == '-'
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "LHa archive"
+	},
+}
+
+var matcherSquashfs = fileMatcher{
+	name:   "squashfs",
+	minLen: 4,
+	mime:   "application/x-squashfs",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && (hasPrefix(b, "sqsh") || hasPrefix(b, "hsqs") ||
+			hasPrefix(b, "sqlz") || hasPrefix(b, "qshs"))
+	},
+	details: describeSquashfs,
+}
+
+var matcherZlib = fileMatcher{
+	name:       "zlib",
+	minLen:     2,
+	mime:       "application/zlib",
+	confidence: 40, // 2-byte header checksum
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		// First byte 0x78 = deflate with 32KB window; second byte must satisfy
+		// (CMF*256 + FLG) % 31 == 0. Common values: 0x789C, 0x78DA, 0x7801, 0x785E.
+		return lenb >= 2 && b[0] == 0x78 && (uint16(b[0])<<8+uint16(b[1]))%31 == 0
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "zlib compressed data"
+	},
+}
+
+var matcherCab = fileMatcher{
+	name:   "cab",
+	minLen: 17,
+	mime:   "application/vnd.ms-cab-compressed",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 16 && hasPrefix(b, "\x4D\x53\x43\x46")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "Microsoft Cabinet file"
+	},
+}
+
+func doZip(file *source) string {
+	// Function distinguishes between Office XML documents and regular zip files.
+	// First read the intial bytes of the file, and see if common Word, Excel, Powerpoint strings are present.
+	// Next, there are some other strings that seem to appear in Office documents of multiple types; For these,
+	// we will open the zip real quick and look for the Word, Excel, PowerPoint xml file, or epub mimetype file. Otherwise it is a regular zip.
+
+	if file == nil {
+		return "Zip archive data"
+	}
+
+	zipReader, zipErr := zip.NewReader(file, file.size)
+	if zipErr == nil {
+		if desc := ooxmlDescription(zipReader); desc != "" {
+			return desc
+		}
+	}
+
+	// Read the first 60 bytes from the file
+	var buf [60]byte
+	n, err := file.ReadAt(buf[:], 0)
+	if err != nil && err != io.EOF {
+		return "File error."
+	}
+
+	// Convert the bytes to a string
+	str := string(buf[:n])
+
+	// Some files have strings in the header indicating the type of Office program or document
+	switch {
+	case strings.Contains(str, "word/") && strings.Contains(str, "xml"):
+		return "Microsoft Word 2007+"
+	case strings.Contains(str, "ppt/theme"):
+		return "Microsoft PowerPoint 2007+"
+	case strings.Contains(str, "xl/") && strings.Contains(str, "xml"):
+		return "Microsoft Excel 2007+"
+	default:
+		// Otherwise inspect entries and look for document, workbook, presentation xml, or epub/OpenDocument markers.
+		if zipErr != nil {
+			return "Unknown file type"
+		}
+		hasContentTypes := false
+		hasRels := false
+		hasXMLPayload := false
+		hasAPKManifest := false
+		hasDexPayload := false
+		hasAABManifest := false
+		hasAABBundleConfig := false
+		hasKMZDoc := false
+		hasIPSWBuildManifest := false
+		hasIPSWRestorePlist := false
+		hasJarManifest := false
+		hasWarWebInf := false
+		hasEarAppXML := false
+		hasNuspec := false
+		hasNugetMeta := false
+		hasVsixManifest := false
+
+		// Loop through the files in the ZIP archive
+		for _, zipFile := range zipReader.File {
+			lowerName := strings.ToLower(zipFile.Name)
+			switch zipFile.Name {
+			case "word/document.xml":
+				return "Microsoft Word 2007+"
+			case "xl/workbook.xml":
+				return "Microsoft Excel 2007+"
+			case "ppt/presentation.xml":
+				return "Microsoft PowerPoint 2007+"
+			case "mimetype":
+				file, err := zipFile.Open()
+				if err != nil {
+					return "Error opening file"
+				}
+				defer file.Close()
+				// Check for OpenDocument or ePub format
+				first200Bytes := make([]byte, 200)
+				_, err = file.Read(first200Bytes)
+				if err != nil {
+					return "Error reading first 200 bytes"
+				}
+				mimeSample := strings.TrimSpace(string(first200Bytes))
+				if desc := openDocumentDescriptionForMIME(mimeSample); desc != "" {
+					return desc + odfMacroSuffix(zipReader)
+				}
+				if strings.Contains(mimeSample, "epub") {
+					return "EPUB document"
+				}
+			}
+
+			if lowerName == "appmanifest.xaml" || lowerName == "wmappmanifest.xml" {
+				return "Microsoft Silverlight Application"
+			}
+			if lowerName == "designmap.xml" {
+				return "Adobe InDesign IDML package"
+			}
+			if lowerName == "androidmanifest.xml" {
+				hasAPKManifest = true
+			}
+			if lowerName == "classes.dex" || (strings.HasPrefix(lowerName, "classes") && strings.HasSuffix(lowerName, ".dex")) {
+				hasDexPayload = true
+			}
+			if lowerName == "base/manifest/androidmanifest.xml" {
+				hasAABManifest = true
+			}
+			if lowerName == "bundleconfig.pb" {
+				hasAABBundleConfig = true
+			}
+			if lowerName == "doc.kml" {
+				hasKMZDoc = true
+			}
+			if lowerName == "buildmanifest.plist" {
+				hasIPSWBuildManifest = true
+			}
+			if lowerName == "restore.plist" {
+				hasIPSWRestorePlist = true
+			}
+			if lowerName == "meta-inf/manifest.mf" {
+				hasJarManifest = true
+			}
+			if strings.HasPrefix(lowerName, "web-inf/") {
+				hasWarWebInf = true
+			}
+			if lowerName == "meta-inf/application.xml" {
+				hasEarAppXML = true
+			}
+			if strings.HasSuffix(lowerName, ".nuspec") {
+				hasNuspec = true
+			}
+			if strings.HasPrefix(lowerName, "package/services/metadata/core-properties/") && strings.HasSuffix(lowerName, ".psmdcp") {
+				hasNugetMeta = true
+			}
+			if lowerName == "extension.vsixmanifest" || lowerName == "vsixmanifest" {
+				hasVsixManifest = true
+			}
+			if lowerName == "[content_types].xml" {
+				hasContentTypes = true
+			}
+			if strings.HasPrefix(lowerName, "_rels/") {
+				hasRels = true
+			}
+			if strings.HasSuffix(lowerName, ".xml") {
+				hasXMLPayload = true
+			}
+		}
+
+		if hasAPKManifest && hasDexPayload {
+			return "Android application package (APK)"
+		}
+		if hasAABManifest && (hasAABBundleConfig || hasDexPayload) {
+			return "Android app bundle (AAB)"
+		}
+		if hasKMZDoc {
+			return "KMZ geospatial archive"
+		}
+		if hasIPSWBuildManifest && hasIPSWRestorePlist {
+			return "Apple IPSW firmware package"
+		}
+		if hasVsixManifest {
+			return "Visual Studio extension package (VSIX)"
+		}
+		if hasNuspec || (hasNugetMeta && hasContentTypes) {
+			return "NuGet package (NUPKG)"
+		}
+		if hasWarWebInf {
+			return "Java WAR archive"
+		}
+		if hasEarAppXML {
+			return "Java EAR archive"
+		}
+		if hasJarManifest {
+			return "Java JAR archive"
+		}
+		if hasContentTypes && hasRels && hasXMLPayload {
+			return "Microsoft OOXML"
+		}
+		if desc := openDocumentDescriptionForMIME(odfManifestType(zipReader)); desc != "" {
+			return desc + odfMacroSuffix(zipReader)
+		}
+
+		return fmt.Sprintf("Zip archive data, %d files", len(zipReader.File))
+	}
+}
+
+func openDocumentDescriptionForMIME(mime string) string {
+	switch mime {
+	case "application/vnd.oasis.opendocument.text":
+		return "OpenDocument text"
+	case "application/vnd.oasis.opendocument.text-template":
+		return "OpenDocument text template"
+	case "application/vnd.oasis.opendocument.text-web":
+		return "OpenDocument text web"
+	case "application/vnd.oasis.opendocument.text-master":
+		return "OpenDocument text master"
+	case "application/vnd.oasis.opendocument.spreadsheet":
+		return "OpenDocument spreadsheet"
+	case "application/vnd.oasis.opendocument.spreadsheet-template":
+		return "OpenDocument spreadsheet template"
+	case "application/vnd.oasis.opendocument.presentation":
+		return "OpenDocument presentation"
+	case "application/vnd.oasis.opendocument.presentation-template":
+		return "OpenDocument presentation template"
+	case "application/vnd.oasis.opendocument.graphics":
+		return "OpenDocument graphics"
+	case "application/vnd.oasis.opendocument.graphics-template":
+		return "OpenDocument graphics template"
+	case "application/vnd.oasis.opendocument.chart":
+		return "OpenDocument chart"
+	case "application/vnd.oasis.opendocument.chart-template":
+		return "OpenDocument chart template"
+	case "application/vnd.oasis.opendocument.image":
+		return "OpenDocument image"
+	case "application/vnd.oasis.opendocument.image-template":
+		return "OpenDocument image template"
+	case "application/vnd.oasis.opendocument.formula":
+		return "OpenDocument formula"
+	case "application/vnd.oasis.opendocument.formula-template":
+		return "OpenDocument formula template"
+	case "application/vnd.oasis.opendocument.database":
+		return "OpenDocument database"
+	case "application/vnd.oasis.opendocument":
+		return "OpenDocument"
+	default:
+		if strings.HasPrefix(mime, "application/vnd.oasis.opendocument.") {
+			return "OpenDocument"
+		}
+		return ""
+	}
+}
+
+// ooxmlType is an Office Open XML document type, identified by the content
+// type [Content_Types].xml gives its main part.
+type ooxmlType struct {
+	contentType string
+	desc        string
+	mime        string
+}
+
+var ooxmlTypes = []ooxmlType{
+	{"application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml", "Microsoft Word 2007+", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+	{"application/vnd.openxmlformats-officedocument.wordprocessingml.template.main+xml", "Microsoft Word 2007+ template", "application/vnd.openxmlformats-officedocument.wordprocessingml.template"},
+	{"application/vnd.ms-word.document.macroEnabled.main+xml", "Microsoft Word 2007+ macro-enabled document", "application/vnd.ms-word.document.macroEnabled.12"},
+	{"application/vnd.ms-word.template.macroEnabledTemplate.main+xml", "Microsoft Word 2007+ macro-enabled template", "application/vnd.ms-word.template.macroEnabled.12"},
+	{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml", "Microsoft Excel 2007+", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+	{"application/vnd.openxmlformats-officedocument.spreadsheetml.template.main+xml", "Microsoft Excel 2007+ template", "application/vnd.openxmlformats-officedocument.spreadsheetml.template"},
+	{"application/vnd.ms-excel.sheet.macroEnabled.main+xml", "Microsoft Excel 2007+ macro-enabled workbook", "application/vnd.ms-excel.sheet.macroEnabled.12"},
+	{"application/vnd.ms-excel.template.macroEnabled.main+xml", "Microsoft Excel 2007+ macro-enabled template", "application/vnd.ms-excel.template.macroEnabled.12"},
+	{"application/vnd.ms-excel.addin.macroEnabled.main+xml", "Microsoft Excel 2007+ macro-enabled add-in", "application/vnd.ms-excel.addin.macroEnabled.12"},
+	{"application/vnd.ms-excel.sheet.binary.macroEnabled.main", "Microsoft Excel 2007+ binary workbook", "application/vnd.ms-excel.sheet.binary.macroEnabled.12"},
+	{"application/vnd.openxmlformats-officedocument.presentationml.presentation.main+xml", "Microsoft PowerPoint 2007+", "application/vnd.openxmlformats-officedocument.presentationml.presentation"},
+	{"application/vnd.openxmlformats-officedocument.presentationml.template.main+xml", "Microsoft PowerPoint 2007+ template", "application/vnd.openxmlformats-officedocument.presentationml.template"},
+	{"application/vnd.openxmlformats-officedocument.presentationml.slideshow.main+xml", "Microsoft PowerPoint 2007+ slideshow", "application/vnd.openxmlformats-officedocument.presentationml.slideshow"},
+	{"application/vnd.ms-powerpoint.presentation.macroEnabled.main+xml", "Microsoft PowerPoint 2007+ macro-enabled presentation", "application/vnd.ms-powerpoint.presentation.macroEnabled.12"},
+	{"application/vnd.ms-powerpoint.template.macroEnabled.main+xml", "Microsoft PowerPoint 2007+ macro-enabled template", "application/vnd.ms-powerpoint.template.macroEnabled.12"},
+	{"application/vnd.ms-powerpoint.slideshow.macroEnabled.main+xml", "Microsoft PowerPoint 2007+ macro-enabled slideshow", "application/vnd.ms-powerpoint.slideshow.macroEnabled.12"},
+	{"application/vnd.ms-powerpoint.addin.macroEnabled.main+xml", "Microsoft PowerPoint 2007+ macro-enabled add-in", "application/vnd.ms-powerpoint.addin.macroEnabled.12"},
+	{"application/vnd.ms-visio.drawing.main+xml", "Microsoft Visio 2013+ drawing", "application/vnd.ms-visio.drawing"},
+	{"application/vnd.ms-visio.template.main+xml", "Microsoft Visio 2013+ template", "application/vnd.ms-visio.template"},
+	{"application/vnd.ms-visio.stencil.main+xml", "Microsoft Visio 2013+ stencil", "application/vnd.ms-visio.stencil"},
+	{"application/vnd.ms-visio.drawing.macroEnabled.main+xml", "Microsoft Visio 2013+ macro-enabled drawing", "application/vnd.ms-visio.drawing.macroEnabled.12"},
+	{"application/vnd.ms-visio.template.macroEnabled.main+xml", "Microsoft Visio 2013+ macro-enabled template", "application/vnd.ms-visio.template.macroEnabled.12"},
+	{"application/vnd.ms-visio.stencil.macroEnabled.main+xml", "Microsoft Visio 2013+ macro-enabled stencil", "application/vnd.ms-visio.stencil.macroEnabled.12"},
+}
+
+const vbaProjectContentType = "application/vnd.ms-office.vbaProject"
+
+// ooxmlDescription names the exact Office Open XML document type from
+// [Content_Types].xml, noting Strict conformance and a VBA project
+// (vbaProject.bin), which is what makes a document carry macros. It returns
+// "" when no main part type is recognised.
+func ooxmlDescription(zr *zip.Reader) string {
+	data := zipEntry(zr, "[Content_Types].xml")
+	if data == nil {
+		return ""
+	}
+	var types struct {
+		Defaults []struct {
+			ContentType string `xml:"ContentType,attr"`
+		} `xml:"Default"`
+		Overrides []struct {
+			ContentType string `xml:"ContentType,attr"`
+		} `xml:"Override"`
+	}
+	if xml.Unmarshal(data, &types) != nil {
+		return ""
+	}
+
+	var main *ooxmlType
+	macros := false
+	for _, o := range types.Overrides {
+		if o.ContentType == vbaProjectContentType {
+			macros = true
+		}
+		for i := range ooxmlTypes {
+			if main == nil && strings.EqualFold(o.ContentType, ooxmlTypes[i].contentType) {
+				main = &ooxmlTypes[i]
+			}
+		}
+	}
+	if main == nil {
+		return ""
+	}
+	for _, d := range types.Defaults {
+		if d.ContentType == vbaProjectContentType {
+			macros = true
+		}
+	}
+	for _, f := range zr.File {
+		if strings.EqualFold(path.Base(f.Name), "vbaProject.bin") {
+			macros = true
+		}
+	}
+
+	desc := main.desc
+	// Strict documents use ISO namespaces for their package relationships.
+	if bytes.Contains(zipEntry(zr, "_rels/.rels"), []byte("http://purl.oclc.org/ooxml/officeDocument/relationships/")) {
+		desc += " (Strict Open XML)"
+	}
+	if macros {
+		desc += ", with VBA macros"
+	}
+	return desc
+}
+
+// ooxmlMIME returns the MIME type of the longest ooxmlTypes description
+// that starts dl, a lower-cased description.
+func ooxmlMIME(dl string) string {
+	best, mime := 0, ""
+	for _, t := range ooxmlTypes {
+		if len(t.desc) > best && strings.HasPrefix(dl, strings.ToLower(t.desc)) {
+			best, mime = len(t.desc), t.mime
+		}
+	}
+	return mime
+}
+
+// odfManifestType returns the package media type META-INF/manifest.xml
+// declares, for OpenDocument files without a mimetype entry.
+func odfManifestType(zr *zip.Reader) string {
+	data := zipEntry(zr, "META-INF/manifest.xml")
+	if data == nil {
+		return ""
+	}
+	var manifest struct {
+		Entries []struct {
+			FullPath  string `xml:"full-path,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"file-entry"`
+	}
+	if xml.Unmarshal(data, &manifest) != nil {
+		return ""
+	}
+	for _, e := range manifest.Entries {
+		if e.FullPath == "/" {
+			return e.MediaType
+		}
+	}
+	return ""
+}
+
+// odfMacroSuffix reports Basic modules (Basic/<library>/<module>.xml) and
+// Scripts/ entries in an OpenDocument package.
+func odfMacroSuffix(zr *zip.Reader) string {
+	for _, f := range zr.File {
+		name := f.Name
+		if strings.HasPrefix(name, "Scripts/") && !strings.HasSuffix(name, "/") {
+			return ", with macros"
+		}
+		if strings.HasPrefix(name, "Basic/") && strings.Count(name, "/") == 2 &&
+			strings.HasSuffix(name, ".xml") && path.Base(name) != "script-lb.xml" {
+			return ", with macros"
+		}
+	}
+	return ""
+}
+
+// zipEntry returns up to 1MiB of the named entry, nil when it is missing.
+func zipEntry(zr *zip.Reader, name string) []byte {
+	for _, f := range zr.File {
+		if !strings.EqualFold(f.Name, name) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(io.LimitReader(rc, 1<<20))
+		if err != nil {
+			return nil
+		}
+		return data
+	}
+	return nil
+}
+
+func doTar(file *source) string {
+	// OVA is a tar containing at least one .ovf descriptor file.
+	if file == nil {
+		return "Posix tar archive"
+	}
+	tr := tar.NewReader(file.reader())
+	const maxEntries = 200
+	for i := 0; i < maxEntries; i++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "Posix tar archive"
+		}
+		if strings.HasSuffix(strings.ToLower(hdr.Name), ".ovf") {
+			return "VMware OVA appliance"
+		}
+	}
+
+	return "Posix tar archive"
+}
+
+// doAr describes an ar archive. Debian packages also get the Package,
+// Version, Architecture and Maintainer fields of their control file.
+func doAr(file *source) (string, map[string]any) {
+	if file == nil {
+		return "ar archive", nil
+	}
+	r := file.reader()
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil || !bytes.Equal(header, []byte("!<arch>\n")) {
+		return "ar archive", nil
+	}
+
+	const maxEntries = 200
+
+	hasDebianBinary := false
+	format := ""
+	controlTar := ""
+	dataComp := ""
+	var control map[string]string
+
+	for i := 0; i < maxEntries; i++ {
+		hdr := make([]byte, 60)
+		_, err := io.ReadFull(r, hdr)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return "ar archive", nil
+		}
+		if hdr[58] != '`' || hdr[59] != '\n' {
+			return "ar archive", nil
+		}
+
+		name := normalizeArEntryName(string(hdr[:16]))
+		lower := strings.ToLower(name)
+
+		sizeStr := strings.TrimSpace(string(hdr[48:58]))
+		size, err := strconv.Atoi(sizeStr)
+		if err != nil || size < 0 {
+			return "ar archive", nil
+		}
+
+		readAndDiscard := func(n int) error {
+			if n <= 0 {
+				return nil
+			}
+			_, err := io.CopyN(io.Discard, r, int64(n))
+			return err
+		}
+
+		readN := 0
+		switch {
+		case lower == "debian-binary":
+			hasDebianBinary = true
+			readN = size
+			if readN > 64 {
+				readN = 64
+			}
+			buf := make([]byte, readN)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return "ar archive", nil
+			}
+			format = strings.TrimSpace(string(buf))
+		case lower == "control.tar" || strings.HasPrefix(lower, "control.tar."):
+			controlTar = name
+			if pos, err := r.Seek(0, io.SeekCurrent); err == nil {
+				control = readDebControl(io.NewSectionReader(file, pos, int64(size)), lower)
+			}
+		case strings.HasPrefix(lower, "data.tar."):
+			dataComp = tarPayloadCompression(lower)
+		}
+
+		if err := readAndDiscard(size - readN); err != nil {
+			return "ar archive", nil
+		}
+		if size%2 != 0 {
+			if _, err := r.Seek(1, io.SeekCurrent); err != nil {
+				return "ar archive", nil
+			}
+		}
+	}
+
+	if hasDebianBinary && controlTar != "" {
+		if format == "" {
+			format = "2.0"
+		}
+		desc := "Debian binary package (format " + format + ")"
+		var fields map[string]any
+		if pkg := control["package"]; pkg != "" {
+			// Named like dpkg-name would: the epoch is not part of it.
+			version := control["version"]
+			if i := strings.IndexByte(version, ':'); i >= 0 {
+				version = version[i+1:]
+			}
+			desc += ", " + pkg + "_" + version + "_" + control["architecture"]
+			// Keyed like the RPM fields, so both package formats read alike.
+			fields = map[string]any{}
+			for key, field := range map[string]string{"package": "name", "version": "version", "architecture": "arch", "maintainer": "maintainer"} {
+				if v := control[key]; v != "" {
+					fields[field] = v
+				}
+			}
+		}
+		desc += ", with " + controlTar
+		if dataComp != "" {
+			desc += ", data compression " + dataComp
+		}
+		return desc, fields
+	}
+	return "ar archive", nil
+}
+
+// readDebControl extracts the control file from a Debian package's
+// control.tar member and returns its first paragraph, keyed by lower-case
+// field name. It returns nil when the member cannot be read.
+func readDebControl(member io.Reader, name string) map[string]string {
+	var r io.Reader = member
+	if comp := tarPayloadCompression(name); comp != "" {
+		if comp == "zst" || comp == "zs" {
+			comp = "zstd"
+		}
+		open, ok := decompressors[comp]
+		if !ok {
+			return nil
+		}
+		dec, err := open(member)
+		if err != nil {
+			return nil
+		}
+		if c, ok := dec.(io.Closer); ok {
+			defer c.Close()
+		}
+		r = dec
+	}
+
+	tr := tar.NewReader(r)
+	for i := 0; i < 200; i++ {
+		hdr, err := tr.Next()
+		if err != nil {
+			return nil
+		}
+		if path.Clean(hdr.Name) != "control" {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, 64<<10))
+		if err != nil {
+			return nil
+		}
+		return parseDebControl(data)
+	}
+	return nil
+}
+
+// parseDebControl parses the first paragraph of a deb822 control file.
+// Continuation lines are appended to the preceding field.
+func parseDebControl(data []byte) map[string]string {
+	fields := map[string]string{}
+	last := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		switch {
+		case strings.TrimSpace(line) == "":
+			if len(fields) > 0 {
+				return fields
+			}
+		case line[0] == ' ' || line[0] == '\t':
+			if last != "" {
+				fields[last] += "\n" + strings.TrimSpace(line)
+			}
+		default:
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			last = strings.ToLower(strings.TrimSpace(key))
+			fields[last] = strings.TrimSpace(value)
+		}
+	}
+	return fields
+}
+
+// RPM header tags doRpm reports.
+const (
+	rpmTagName              = 1000
+	rpmTagVersion           = 1001
+	rpmTagRelease           = 1002
+	rpmTagEpoch             = 1003
+	rpmTagArch              = 1022
+	rpmTagPayloadCompressor = 1125
+)
+
+// doRpm decodes the RPM lead and main header: "RPM v3.0 bin x86_64 foo-1.2-3".
+func doRpm(b []byte, file *source) (string, map[string]any) {
+	kind := "bin"
+	if peekBe(b[6:], 2) == 1 {
+		kind = "src"
+	}
+	desc := fmt.Sprintf("RPM v%d.%d %s", b[4], b[5], kind)
+
+	// The signature header follows the 96-byte lead, padded to 8 bytes.
+	_, end, ok := rpmHeaderAt(b, file, 96)
+	if ok {
+		end += (8 - end%8) % 8
+	}
+	var tags map[int]string
+	if ok {
+		tags, _, ok = rpmHeaderAt(b, file, end)
+	}
+	if !ok || tags[rpmTagName] == "" {
+		// Fall back to the lead's name-version-release.
+		name := b[10:76]
+		if i := bytes.IndexByte(name, 0); i >= 0 {
+			name = name[:i]
+		}
+		if len(name) > 0 && isPrintableASCII(string(name)) {
+			desc += " " + string(name)
+		}
+		return desc, nil
+	}
+
+	fields := map[string]any{"package_type": kind}
+	if arch := tags[rpmTagArch]; arch != "" {
+		desc += " " + arch
+		fields["arch"] = arch
+	}
+	nvr := tags[rpmTagName]
+	for _, v := range []string{tags[rpmTagVersion], tags[rpmTagRelease]} {
+		if v != "" {
+			nvr += "-" + v
+		}
+	}
+	desc += " " + nvr
+	fields["name"] = tags[rpmTagName]
+	for _, tag := range []struct {
+		id  int
+		key string
+	}{
+		{rpmTagVersion, "version"},
+		{rpmTagRelease, "release"},
+		{rpmTagEpoch, "epoch"},
+		{rpmTagPayloadCompressor, "payload_compressor"},
+	} {
+		if v := tags[tag.id]; v != "" {
+			fields[tag.key] = v
+		}
+	}
+	return desc, fields
+}
+
+// rpmHeaderAt decodes the header structure at off, returning the string and
+// INT32 values of the tags doRpm reports and the offset just past it.
+func rpmHeaderAt(b []byte, file *source, off int) (map[int]string, int, bool) {
+	intro, ok := readAt(b, file, off, 16)
+	if !ok || !hasPrefix(intro, "\x8E\xAD\xE8\x01") {
+		return nil, 0, false
+	}
+	nindex := peekBe(intro[8:], 4)
+	hsize := peekBe(intro[12:], 4)
+	if nindex > 0xFFFF || hsize > 256<<20 {
+		return nil, 0, false
+	}
+	index, ok := readAt(b, file, off+16, nindex*16)
+	if !ok {
+		return nil, 0, false
+	}
+	store := off + 16 + nindex*16
+
+	tags := map[int]string{}
+	for i := 0; i < nindex; i++ {
+		e := index[i*16:]
+		tag, typ, dataOff := peekBe(e, 4), peekBe(e[4:], 4), peekBe(e[8:], 4)
+		if tag < rpmTagName || tag > rpmTagPayloadCompressor || dataOff >= hsize {
+			continue
+		}
+		switch typ {
+		case 4: // INT32
+			if v, ok := readAt(b, file, store+dataOff, 4); ok {
+				tags[tag] = strconv.Itoa(peekBe(v, 4))
+			}
+		case 6, 8, 9: // STRING, STRING_ARRAY, I18NSTRING: the first string
+			v, ok := readAt(b, file, store+dataOff, min(256, hsize-dataOff))
+			if !ok {
+				continue
+			}
+			if j := bytes.IndexByte(v, 0); j >= 0 {
+				v = v[:j]
+			}
+			if isPrintableASCII(string(v)) {
+				tags[tag] = string(v)
+			}
+		}
+	}
+	return tags, store + hsize, true
+}
+
+func normalizeArEntryName(name string) string {
+	name = strings.TrimSpace(name)
+	name = strings.TrimSuffix(name, "/")
+	return name
+}
+
+func tarPayloadCompression(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".zst"):
+		return "zst"
+	case strings.HasSuffix(name, ".zs"):
+		return "zs"
+	case strings.HasSuffix(name, ".xz"):
+		return "xz"
+	case strings.HasSuffix(name, ".gz"):
+		return "gzip"
+	case strings.HasSuffix(name, ".bz2"):
+		return "bzip2"
+	default:
+		return ""
+	}
+}
+
+// appendedZipCandidate finds a zip archive glued onto the end of another file
+// (self-extracting executables, polyglots) through its end of central
+// directory record. primary is the matcher that claimed the file start.
+func appendedZipCandidate(file *source, primary string) (Candidate, bool) {
+	if file == nil || primary == "zip" {
+		return Candidate{}, false
+	}
+	// EOCD is 22 bytes followed by a comment of at most 64KiB.
+	n := int64(22 + 0xFFFF)
+	if n > file.size {
+		n = file.size
+	}
+	tail, ok := readTail(file, int(n))
+	if !ok {
+		return Candidate{}, false
+	}
+	i := bytes.LastIndex(tail, []byte("PK\x05\x06"))
+	if i < 0 || len(tail)-i < 22 {
+		return Candidate{}, false
+	}
+	eocd := tail[i:]
+	cdSize := int64(peekLe(eocd[12:], 4))
+	cdOffset := int64(peekLe(eocd[16:], 4))
+	start := file.size - n + int64(i) - cdSize - cdOffset
+	if start <= 0 {
+		return Candidate{}, false
+	}
+	return Candidate{
+		Description: fmt.Sprintf("Zip archive data, appended at offset %d", start),
+		MIME:        "application/zip",
+		Matcher:     "zip",
+		Confidence:  60,
+	}, true
+}
+
+var gzipOS = map[byte]string{
+	0:  "FAT filesystem (MS-DOS, OS/2, NT)",
+	1:  "Amiga",
+	2:  "VMS",
+	3:  "Unix",
+	4:  "VM/CMS",
+	5:  "Atari TOS",
+	6:  "HPFS filesystem (OS/2, NT)",
+	7:  "Macintosh",
+	8:  "Z-System",
+	9:  "CP/M",
+	10: "TOPS-20",
+	11: "NTFS filesystem (NT)",
+	12: "QDOS",
+	13: "Acorn RISCOS",
+}
+
+// doGzip reports the RFC 1952 header fields in GNU file's wording, plus the
+// trailer's ISIZE and whether the stream is BGZF or has several members.
+func doGzip(b []byte, file *source) string {
+	desc := "gzip compressed data"
+	if len(b) < 10 || b[2] != 8 {
+		return desc
+	}
+	flags := b[3]
+	pos := 10
+
+	bgzfBlock := 0
+	if flags&0x04 != 0 { // FEXTRA
+		if len(b) < pos+2 {
+			return desc
+		}
+		xlen := peekLe(b[pos:], 2)
+		pos += 2
+		if len(b) < pos+xlen {
+			return desc
+		}
+		extra := b[pos : pos+xlen]
+		for len(extra) >= 4 {
+			slen := peekLe(extra[2:], 2)
+			if len(extra) < 4+slen {
+				break
+			}
+			if extra[0] == 'B' && extra[1] == 'C' && slen == 2 {
+				bgzfBlock = peekLe(extra[4:], 2) + 1
+			}
+			extra = extra[4+slen:]
+		}
+		pos += xlen
+	}
+	cString := func() (string, bool) {
+		end := bytes.IndexByte(b[pos:], 0)
+		if end < 0 {
+			return "", false
+		}
+		s := string(b[pos : pos+end])
+		pos += end + 1
+		return s, true
+	}
+	var name, comment string
+	if flags&0x08 != 0 { // FNAME
+		var ok bool
+		if name, ok = cString(); !ok {
+			return desc
+		}
+	}
+	if flags&0x10 != 0 { // FCOMMENT
+		var ok bool
+		if comment, ok = cString(); !ok {
+			return desc
+		}
+	}
+
+	if bgzfBlock > 0 {
+		desc += ", Blocked GNU Zip Format (BGZF)"
+	}
+	if name != "" {
+		desc += fmt.Sprintf(", was %q", name)
+	}
+	if comment != "" {
+		desc += fmt.Sprintf(", comment %q", comment)
+	}
+	if mtime := peekLe(b[4:], 4); mtime != 0 {
+		desc += ", last modified: " + time.Unix(int64(mtime), 0).UTC().Format("Mon Jan _2 15:04:05 2006")
+	}
+	switch b[8] {
+	case 2:
+		desc += ", max compression"
+	case 4:
+		desc += ", max speed"
+	}
+	if system, ok := gzipOS[b[9]]; ok {
+		desc += ", from " + system
+	}
+
+	if bgzfBlock > 0 {
+		// Every BGZF block is a member and the last one is an empty EOF
+		// marker, so neither multi-member nor ISIZE says anything.
+		return desc
+	}
+	// ISIZE only covers the last member, so it is shown only once the first
+	// member is known to be the last.
+	end, ok := gzipMemberEnd(file)
+	if !ok {
+		return desc
+	}
+	if end < file.size {
+		next := make([]byte, 2)
+		if _, err := file.ReadAt(next, end); err == nil && bytes.Equal(next, []byte{0x1f, 0x8b}) {
+			return desc + ", multi-member"
+		}
+	}
+	if tail, ok := readTail(file, 4); ok {
+		desc += fmt.Sprintf(", original size modulo 2^32 %d", peekLe(tail, 4))
+	}
+	return desc
+}
+
+// gzipMemberEnd decodes the first gzip member and returns the offset just
+// past its trailer. It runs on every gzip file, so it gives up on members
+// that inflate beyond a few hundred KiB.
+func gzipMemberEnd(file *source) (int64, bool) {
+	if file == nil {
+		return 0, false
+	}
+	cr := &countingReader{r: bufio.NewReader(file.reader())}
+	zr, err := gzip.NewReader(cr)
+	if err != nil {
+		return 0, false
+	}
+	zr.Multistream(false)
+	const maxInflate = 256 << 10
+	n, err := io.CopyN(io.Discard, zr, maxInflate+1)
+	if err != io.EOF || n > maxInflate {
+		return 0, false
+	}
+	return cr.n, true
+}
+
+// countingReader counts the bytes consumed through it. It is a ByteReader so
+// compress/flate reads no further than the end of the deflate stream.
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
@@ -1,8 +1,7 @@
-package main
+package magic
 
 import (
 	"bytes"
-	"os"
 	"strings"
 )
 
@@ -10,12 +9,12 @@ var matcherIndd = fileMatcher{
 	name:   "indd",
 	minLen: 16,
 	mime:   "application/x-indesign",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		// Adobe InDesign binary document signature.
 		sig := []byte{0x06, 0x06, 0xED, 0xF5, 0xD8, 0x1D, 0x46, 0xE5, 0xBD, 0x31, 0xEF, 0xE7, 0xFE, 0x74, 0xB7, 0x1D}
 		return lenb >= len(sig) && bytes.Equal(b[:len(sig)], sig)
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Adobe InDesign document"
 	},
 }
@@ -24,10 +23,10 @@ var matcherDwg = fileMatcher{
 	name:   "dwg",
 	minLen: 6,
 	mime:   "image/vnd.dwg",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb >= 6 && HasPrefix(b, "AC10") && isDigitByte(b[4]) && isDigitByte(b[5])
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 6 && hasPrefix(b, "AC10") && isDigitByte(b[4]) && isDigitByte(b[5])
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "AutoCAD DWG drawing"
 	},
 }
@@ -36,10 +35,10 @@ var matcherDxf = fileMatcher{
 	name:   "dxf",
 	minLen: 12,
 	mime:   "image/vnd.dxf",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		return looksLikeDXFDocument(b)
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "AutoCAD DXF drawing exchange format"
 	},
 }
@@ -48,7 +47,7 @@ var matcherStep = fileMatcher{
 	name:   "step",
 	minLen: 16,
 	mime:   "model/step",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		if lenb < 16 || !isText(b) {
 			return false
 		}
@@ -59,7 +58,7 @@ var matcherStep = fileMatcher{
 		s := bytes.ToUpper(bytes.TrimSpace(stripUTF8BOM(b[:end])))
 		return bytes.HasPrefix(s, []byte("ISO-10303-21;"))
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "STEP CAD model"
 	},
 }
@@ -68,7 +67,7 @@ var matcherScribus = fileMatcher{
 	name:   "scribus",
 	minLen: 16,
 	mime:   "application/vnd.scribus",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		if lenb < 16 || !isText(b) {
 			return false
 		}
@@ -79,7 +78,7 @@ var matcherScribus = fileMatcher{
 		s := bytes.ToLower(stripUTF8BOM(b[:end]))
 		return bytes.Contains(s, []byte("<scribusutf8new"))
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Scribus document"
 	},
 }
@@ -88,8 +87,8 @@ var matcherShapefile = fileMatcher{
 	name:   "shapefile",
 	minLen: 36,
 	mime:   "application/x-esri-shape",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		if lenb < 36 || !HasPrefix(b, "\x00\x00\x27\x0A") {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		if lenb < 36 || !hasPrefix(b, "\x00\x00\x27\x0A") {
 			return false
 		}
 		// ESRI shapefile version is little-endian 1000 at offset 28.
@@ -99,7 +98,7 @@ var matcherShapefile = fileMatcher{
 		shapeType := peekLe(b[32:], 4)
 		return shapeType >= 0 && shapeType <= 31
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "ESRI Shapefile data"
 	},
 }
@@ -108,10 +107,10 @@ var matcherLas = fileMatcher{
 	name:   "las",
 	minLen: 4,
 	mime:   "application/vnd.las",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb >= 4 && HasPrefix(b, "LASF")
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && hasPrefix(b, "LASF")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "ASPRS LAS LiDAR data"
 	},
 }
@@ -120,16 +119,14 @@ var matcherGeoPackage = fileMatcher{
 	name:   "geopackage",
 	minLen: 72,
 	mime:   "application/geopackage+sqlite3",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		if lenb < 72 || !HasPrefix(b, "SQLite format 3\x00") {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		if lenb < 72 || !hasPrefix(b, "SQLite format 3\x00") {
 			return false
 		}
 		// SQLite application_id at offset 68 (big-endian) is 'GPKG'.
 		return bytes.Equal(b[68:72], []byte("GPKG"))
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
-		return "OGC GeoPackage database"
-	},
+	details: describeSQLiteAs("OGC GeoPackage database", false),
 }
 
 func looksLikeDXFDocument(b []byte) bool {
@@ -1,56 +1,58 @@
-package main
+package magic
 
 import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
-	"os"
 )
 
 var matcherParquet = fileMatcher{
 	name:   "parquet",
 	minLen: 4,
 	mime:   "application/octet-stream",
-	match: func(b []byte, lenb int, magic int, file *os.File) bool {
-		return lenb >= 4 && HasPrefix(b, "PAR1") && hasParquetFooter(file)
+	match: func(b []byte, lenb int, magic int, file *source) bool {
+		return lenb >= 4 && hasPrefix(b, "PAR1") && hasParquetFooter(file)
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Parquet data"
 	},
+	summary: parquetSummary,
 }
 
 var matcherAvro = fileMatcher{
 	name:   "avro",
 	minLen: 4,
 	mime:   "application/octet-stream",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb >= 4 && HasPrefix(b, "Obj\x01")
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && hasPrefix(b, "Obj\x01")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Avro data"
 	},
+	summary: avroSummary,
 }
 
 var matcherHdf5 = fileMatcher{
 	name:   "hdf5",
 	minLen: 8,
 	mime:   "application/x-hdf5",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb >= 8 && HasPrefix(b, "\x89HDF\x0d\x0a\x1a\x0a")
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 8 && hasPrefix(b, "\x89HDF\x0d\x0a\x1a\x0a")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Hierarchical Data Format (version 5) data"
 	},
+	summary: hdf5Summary,
 }
 
 var matcherNetcdf = fileMatcher{
 	name:   "netcdf",
 	minLen: 4,
 	mime:   "application/x-netcdf",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb >= 4 && (HasPrefix(b, "CDF\x01") || HasPrefix(b, "CDF\x02"))
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && (hasPrefix(b, "CDF\x01") || hasPrefix(b, "CDF\x02"))
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "NetCDF Data Format data"
 	},
 }
@@ -59,22 +61,23 @@ var matcherFeather = fileMatcher{
 	name:   "feather",
 	minLen: 6,
 	mime:   "application/octet-stream",
-	match: func(b []byte, lenb int, magic int, file *os.File) bool {
-		return lenb >= 6 && HasPrefix(b, "ARROW1") && hasArrowFooter(file)
+	match: func(b []byte, lenb int, magic int, file *source) bool {
+		return lenb >= 6 && hasPrefix(b, "ARROW1") && hasArrowFooter(file)
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Apache Arrow Feather"
 	},
+	summary: arrowSummary,
 }
 
 var matcherPgCustomDump = fileMatcher{
 	name:   "pg-custom-dump",
 	minLen: 5,
 	mime:   "application/octet-stream",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb >= 5 && HasPrefix(b, "PGDMP")
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 5 && hasPrefix(b, "PGDMP")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "PostgreSQL custom database dump"
 	},
 }
@@ -83,8 +86,8 @@ var matcherRedisRdb = fileMatcher{
 	name:   "redis-rdb",
 	minLen: 9,
 	mime:   "application/x-redis-rdb",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		if lenb < 9 || !HasPrefix(b, "REDIS") {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		if lenb < 9 || !hasPrefix(b, "REDIS") {
 			return false
 		}
 		for i := 5; i < 9; i++ {
@@ -94,16 +97,17 @@ var matcherRedisRdb = fileMatcher{
 		}
 		return true
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Redis database dump"
 	},
 }
 
 var matcherDbf = fileMatcher{
-	name:   "dbf",
-	minLen: 33,
-	mime:   "application/x-dbf",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	name:       "dbf",
+	minLen:     33,
+	mime:       "application/x-dbf",
+	confidence: 40, // version byte and header ranges
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		if lenb < 33 {
 			return false
 		}
@@ -130,7 +134,7 @@ var matcherDbf = fileMatcher{
 		}
 		return true
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "dBase DBF database"
 	},
 }
@@ -139,17 +143,17 @@ var matcherOutlookStore = fileMatcher{
 	name:   "outlook-store",
 	minLen: 12,
 	mime:   "application/vnd.ms-outlook",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		if lenb < 12 || !HasPrefix(b, "!BDN") {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		if lenb < 12 || !hasPrefix(b, "!BDN") {
 			return false
 		}
-		if !Equal(b[8:10], "SM") {
+		if !equal(b[8:10], "SM") {
 			return false
 		}
 		ver := peekLe(b[10:], 2)
 		return ver == 14 || ver == 15 || ver == 23
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		ver := peekLe(b[10:], 2)
 		if ver == 23 {
 			return "Microsoft Outlook PST/OST message store (Unicode)"
@@ -162,38 +166,20 @@ var matcherSqlite = fileMatcher{
 	name:   "sqlite",
 	minLen: 17,
 	mime:   "application/x-sqlite3",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb > 16 && HasPrefix(b, "\x53\x51\x4C\x69\x74\x65\x20\x66\x6F\x72\x6D\x61\x74\x20\x33\x00")
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 16 && hasPrefix(b, "\x53\x51\x4C\x69\x74\x65\x20\x66\x6F\x72\x6D\x61\x74\x20\x33\x00")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
-		return describeSQLite(b)
-	},
-}
-
-func describeSQLite(b []byte) string {
-	if len(b) < 18 {
-		return "SQLite database"
-	}
-	// Page size at bytes 16-17 big-endian; value 1 means 65536.
-	pageSizeRaw := peekBe(b[16:], 2)
-	pageSize := pageSizeRaw
-	if pageSize == 1 {
-		pageSize = 65536
-	}
-	if pageSize > 0 {
-		return fmt.Sprintf("SQLite database, page size %d", pageSize)
-	}
-	return "SQLite database"
+	details: describeSQLiteAs("SQLite database", true),
 }
 
 var matcherSqliteWal = fileMatcher{
 	name:   "sqlite-wal",
 	minLen: 4,
 	mime:   "application/x-sqlite3",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb >= 4 && (Equal(b[:4], "\x37\x7F\x06\x82") || Equal(b[:4], "\x37\x7F\x06\x83"))
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && (equal(b[:4], "\x37\x7F\x06\x82") || equal(b[:4], "\x37\x7F\x06\x83"))
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "SQLite WAL file"
 	},
 }
@@ -202,8 +188,8 @@ var matcherSqliteJournal = fileMatcher{
 	name:   "sqlite-journal",
 	minLen: 16,
 	mime:   "application/x-sqlite3",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		if lenb <= 15 || !HasPrefix(b, "\x53\x51\x4C\x69\x74\x65\x20\x66\x6F\x72\x6D\x61\x74\x20\x33\x00") {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		if lenb <= 15 || !hasPrefix(b, "\x53\x51\x4C\x69\x74\x65\x20\x66\x6F\x72\x6D\x61\x74\x20\x33\x00") {
 			return false
 		}
 		end := lenb
@@ -212,7 +198,7 @@ var matcherSqliteJournal = fileMatcher{
 		}
 		return bytes.Contains(b[:end], []byte("journal"))
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "SQLite journal file"
 	},
 }
@@ -221,10 +207,10 @@ var matcherPcapng = fileMatcher{
 	name:   "pcapng",
 	minLen: 17,
 	mime:   "application/octet-stream",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb > 16 && HasPrefix(b, "\x0A\x0D\x0D\x0A")
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 16 && hasPrefix(b, "\x0A\x0D\x0D\x0A")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "PCAP-ng capture file"
 	},
 }
@@ -233,11 +219,11 @@ var matcherPcap = fileMatcher{
 	name:   "pcap",
 	minLen: 17,
 	mime:   "application/octet-stream",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		return lenb > 16 &&
-			(HasPrefix(b, "\xD4\xC3\xB2\xA1") || HasPrefix(b, "\xA1\xB2\xC3\xD4") || HasPrefix(b, "\x4D\x3C\xB2\xA1") || HasPrefix(b, "\xA1\xB2\x3C\x4D"))
+			(hasPrefix(b, "\xD4\xC3\xB2\xA1") || hasPrefix(b, "\xA1\xB2\xC3\xD4") || hasPrefix(b, "\x4D\x3C\xB2\xA1") || hasPrefix(b, "\xA1\xB2\x3C\x4D"))
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "PCAP capture file"
 	},
 }
@@ -246,10 +232,10 @@ var matcherTNEF = fileMatcher{
 	name:   "tnef",
 	minLen: 6,
 	mime:   "application/ms-tnef",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb >= 6 && HasPrefix(b, "\x78\x9F\x3E\x22")
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 6 && hasPrefix(b, "\x78\x9F\x3E\x22")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "TNEF attachment data"
 	},
 }
@@ -258,22 +244,22 @@ var matcherGettextMO = fileMatcher{
 	name:   "gettext-mo",
 	minLen: 28,
 	mime:   "application/x-gettext-translation",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		if lenb < 28 {
 			return false
 		}
 		// GNU gettext MO magic (little-endian and big-endian encodings).
-		if !(HasPrefix(b, "\xDE\x12\x04\x95") || HasPrefix(b, "\x95\x04\x12\xDE")) {
+		if !(hasPrefix(b, "\xDE\x12\x04\x95") || hasPrefix(b, "\x95\x04\x12\xDE")) {
 			return false
 		}
 		// Revision is typically 0.0 or 1.0.
 		revision := peekLe(b[4:], 4)
-		if HasPrefix(b, "\x95\x04\x12\xDE") {
+		if hasPrefix(b, "\x95\x04\x12\xDE") {
 			revision = peekBe(b[4:], 4)
 		}
 		return revision == 0 || revision == 1
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "GNU gettext message catalog"
 	},
 }
@@ -282,10 +268,10 @@ var matcherGIRTypelib = fileMatcher{
 	name:   "gir-typelib",
 	minLen: len("GOBJ\nMETADATA\r\n\x1A"),
 	mime:   "application/octet-stream",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb >= len("GOBJ\nMETADATA\r\n\x1A") && HasPrefix(b, "GOBJ\nMETADATA\r\n\x1A")
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= len("GOBJ\nMETADATA\r\n\x1A") && hasPrefix(b, "GOBJ\nMETADATA\r\n\x1A")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "G-IR binary database"
 	},
 }
@@ -294,14 +280,14 @@ var matcherCrx = fileMatcher{
 	name:   "crx",
 	minLen: 12,
 	mime:   "application/x-chrome-extension",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		if lenb < 12 || !HasPrefix(b, "Cr24") {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		if lenb < 12 || !hasPrefix(b, "Cr24") {
 			return false
 		}
 		v := peekLe(b[4:], 4)
 		return v == 2 || v == 3
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Google Chrome extension"
 	},
 }
@@ -310,10 +296,10 @@ var matcherRcc = fileMatcher{
 	name:   "rcc",
 	minLen: 4,
 	mime:   "application/octet-stream",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb >= 4 && HasPrefix(b, "qres")
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && hasPrefix(b, "qres")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Qt Binary Resource file"
 	},
 }
@@ -322,22 +308,20 @@ var matcherLnk = fileMatcher{
 	name:   "lnk",
 	minLen: 20,
 	mime:   "application/x-ms-shortcut",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb >= 20 && HasPrefix(b, "\x4C\x00\x00\x00\x01\x14\x02\x00\x00\x00\x00\x00\xC0\x00\x00\x00\x00\x00\x00\x46")
-	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
-		return "Windows shortcut"
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 20 && hasPrefix(b, "\x4C\x00\x00\x00\x01\x14\x02\x00\x00\x00\x00\x00\xC0\x00\x00\x00\x00\x00\x00\x46")
 	},
+	details: describeLnk,
 }
 
 var matcherChm = fileMatcher{
 	name:   "chm",
 	minLen: 4,
 	mime:   "application/vnd.ms-htmlhelp",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb >= 4 && HasPrefix(b, "ITSF")
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && hasPrefix(b, "ITSF")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "MS Windows HtmlHelp Data"
 	},
 }
@@ -346,19 +330,17 @@ var matcherRegistryHive = fileMatcher{
 	name:   "registry-hive",
 	minLen: 4,
 	mime:   "application/octet-stream",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb >= 4 && HasPrefix(b, "regf")
-	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
-		return "Windows Registry hive"
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && hasPrefix(b, "regf")
 	},
+	details: describeRegistryHive,
 }
 
 var matcherEseDatabase = fileMatcher{
 	name:   "ese-database",
 	minLen: 240,
 	mime:   "application/x-ms-ese",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		if lenb < 240 {
 			return false
 		}
@@ -378,7 +360,7 @@ var matcherEseDatabase = fileMatcher{
 		}
 		return true
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		checksum := uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
 		version := peekLe(b[8:], 4)
 		pageSize := peekLe(b[236:], 4)
@@ -390,7 +372,7 @@ var matcherEseLog = fileMatcher{
 	name:   "ese-log",
 	minLen: 16,
 	mime:   "application/x-ms-ese-log",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		if lenb < 16 {
 			return false
 		}
@@ -399,7 +381,7 @@ var matcherEseLog = fileMatcher{
 			peekLe(b[8:], 4) == 0x00011000 &&
 			peekLe(b[12:], 4) == 0x20000080
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Extensible storage engine transaction log"
 	},
 }
@@ -408,48 +390,44 @@ var matcherPrefetch = fileMatcher{
 	name:   "prefetch",
 	minLen: 8,
 	mime:   "application/octet-stream",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		if lenb < 8 {
 			return false
 		}
 		// Classic prefetch: [version LE][SCCA]
-		if Equal(b[4:8], "SCCA") {
+		if equal(b[4:8], "SCCA") {
 			version := peekLe(b[:4], 4)
 			return version == 0x11 || version == 0x17 || version == 0x1a || version == 0x1e
 		}
 		// Windows compressed prefetch commonly starts with "MAM\x04"
-		if !HasPrefix(b, "MAM\x04") {
+		if !hasPrefix(b, "MAM\x04") {
 			return false
 		}
 		decompressedSize := peekLe(b[4:8], 4)
 		return decompressedSize > 0
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
-		return "Windows Prefetch file"
-	},
+	details: describePrefetch,
 }
 
 var matcherEvtx = fileMatcher{
 	name:   "evtx",
 	minLen: 8,
 	mime:   "application/octet-stream",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb >= 8 && HasPrefix(b, "ElfFile\x00")
-	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
-		return "Windows Event Log"
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 8 && hasPrefix(b, "ElfFile\x00")
 	},
+	details: describeEvtx,
 }
 
 var matcherPdb = fileMatcher{
 	name:   "pdb",
 	minLen: len("Microsoft C/C++ MSF 7.00"),
 	mime:   "application/octet-stream",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		return lenb >= len("Microsoft C/C++ MSF 7.00") &&
-			HasPrefix(b, "Microsoft C/C++ MSF 7.00")
+			hasPrefix(b, "Microsoft C/C++ MSF 7.00")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Microsoft Program Database"
 	},
 }
@@ -458,9 +436,9 @@ var matcherRoslynPdb = fileMatcher{
 	name:   "roslyn-pdb",
 	minLen: 32,
 	mime:   "application/octet-stream",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		// Portable PDB files are ECMA-335 metadata blobs with "BSJB" signature.
-		if lenb < 32 || !HasPrefix(b, "BSJB") {
+		if lenb < 32 || !hasPrefix(b, "BSJB") {
 			return false
 		}
 		// Version string commonly includes "PDB v1.0".
@@ -470,7 +448,7 @@ var matcherRoslynPdb = fileMatcher{
 		}
 		return bytes.Contains(bytes.ToLower(b[:end]), []byte("pdb v1.0"))
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Microsoft Roslyn C# debugging symbols version 1.0"
 	},
 }
@@ -479,11 +457,11 @@ var matcherMagicMgc = fileMatcher{
 	name:   "magic-mgc",
 	minLen: 4,
 	mime:   "application/octet-stream",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		// libmagic compiled magic header (endianness variants).
-		return lenb >= 4 && (HasPrefix(b, "\x1C\x04\x1E\xF1") || HasPrefix(b, "\xF1\x1E\x04\x1C"))
+		return lenb >= 4 && (hasPrefix(b, "\x1C\x04\x1E\xF1") || hasPrefix(b, "\xF1\x1E\x04\x1C"))
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "magic binary file for file(1) cmd"
 	},
 }
@@ -492,10 +470,10 @@ var matcherMinidump = fileMatcher{
 	name:   "minidump",
 	minLen: 4,
 	mime:   "application/octet-stream",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb >= 4 && HasPrefix(b, "MDMP")
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && hasPrefix(b, "MDMP")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Windows minidump"
 	},
 }
@@ -504,19 +482,17 @@ var matcherThumbcache = fileMatcher{
 	name:   "thumbcache",
 	minLen: 4,
 	mime:   "application/octet-stream",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb >= 4 && HasPrefix(b, "CMMM")
-	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
-		return "Windows thumbnail cache"
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && hasPrefix(b, "CMMM")
 	},
+	details: describeThumbcache,
 }
 
 var matcherRecycleBinI = fileMatcher{
 	name:   "recyclebin-i",
 	minLen: 24,
 	mime:   "application/octet-stream",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		if lenb < 24 {
 			return false
 		}
@@ -535,9 +511,7 @@ var matcherRecycleBinI = fileMatcher{
 
 		return hasRecycleBinPathHint(b)
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
-		return "Windows Recycle Bin metadata"
-	},
+	details: describeRecycleBinI,
 }
 
 func hasRecycleBinPathHint(b []byte) bool {
@@ -556,10 +530,10 @@ var matcherTdf = fileMatcher{
 	name:   "tdf",
 	minLen: 17,
 	mime:   "application/octet-stream",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb > 16 && HasPrefix(b, "\x54\x44\x46\x24")
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 16 && hasPrefix(b, "\x54\x44\x46\x24")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Telegram Desktop file"
 	},
 }
@@ -568,10 +542,10 @@ var matcherTdef = fileMatcher{
 	name:   "tdef",
 	minLen: 17,
 	mime:   "application/octet-stream",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb > 16 && HasPrefix(b, "\x54\x44\x45\x46")
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 16 && hasPrefix(b, "\x54\x44\x45\x46")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Telegram Desktop encrypted file"
 	},
 }
@@ -1,10 +1,8 @@
-package main
+package magic
 
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
-	"os"
 	"strings"
 )
 
@@ -12,46 +10,28 @@ var matcherBmp = fileMatcher{
 	name:   "bmp",
 	minLen: 51,
 	mime:   "image/bmp",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb > 50 && HasPrefix(b, "BM") && Equal(b[6:10], "\x00\x00\x00\x00")
-	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
-		return describeBMP(b)
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 50 && hasPrefix(b, "BM") && equal(b[6:10], "\x00\x00\x00\x00")
 	},
-}
-
-func describeBMP(b []byte) string {
-	if len(b) < 30 {
-		return "BMP image"
-	}
-	width := int(int32(peekLe(b[18:], 4)))
-	height := int(int32(peekLe(b[22:], 4)))
-	if height < 0 {
-		height = -height
-	}
-	bpp := peekLe(b[28:], 2)
-	if width > 0 && height > 0 {
-		return fmt.Sprintf("BMP image, %d x %d, %d-bit", width, height, bpp)
-	}
-	return "BMP image"
+	details: describeBMP,
 }
 
 var matcherWmf = fileMatcher{
 	name:   "wmf",
 	minLen: 4,
 	mime:   "image/wmf",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		if lenb < 4 {
 			return false
 		}
 		// Placeable WMF.
-		if HasPrefix(b, "\xD7\xCD\xC6\x9A") {
+		if hasPrefix(b, "\xD7\xCD\xC6\x9A") {
 			return true
 		}
 		// Standard WMF.
-		return HasPrefix(b, "\x01\x00\x09\x00") || HasPrefix(b, "\x02\x00\x09\x00")
+		return hasPrefix(b, "\x01\x00\x09\x00") || hasPrefix(b, "\x02\x00\x09\x00")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Windows metafile"
 	},
 }
@@ -60,33 +40,20 @@ var matcherPdf = fileMatcher{
 	name:   "pdf",
 	minLen: 51,
 	mime:   "application/pdf",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb > 50 && HasPrefix(b, "\x25\x50\x44\x46")
-	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
-		return describePDF(b)
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 50 && hasPrefix(b, "\x25\x50\x44\x46")
 	},
-}
-
-func describePDF(b []byte) string {
-	// Header format: %PDF-X.Y
-	if len(b) >= 8 && HasPrefix(b, "%PDF-") {
-		ver := strings.TrimRight(string(b[5:8]), "\r\n \x00")
-		if len(ver) == 3 && ver[1] == '.' {
-			return "PDF document, version " + ver
-		}
-	}
-	return "PDF document"
+	details: describePDF,
 }
 
 var matcherMobi = fileMatcher{
 	name:   "mobi",
 	minLen: 68,
 	mime:   "application/x-mobipocket-ebook",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb >= 68 && Equal(b[60:68], "BOOKMOBI")
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 68 && equal(b[60:68], "BOOKMOBI")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Mobipocket e-book"
 	},
 }
@@ -95,10 +62,10 @@ var matcherLit = fileMatcher{
 	name:   "lit",
 	minLen: 8,
 	mime:   "application/x-ms-reader",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb >= 8 && HasPrefix(b, "ITOLITLS")
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 8 && hasPrefix(b, "ITOLITLS")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Microsoft Reader eBook"
 	},
 }
@@ -107,33 +74,19 @@ var matcherTiff = fileMatcher{
 	name:   "tiff",
 	minLen: 17,
 	mime:   "image/tiff",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		return lenb > 16 &&
-			(HasPrefix(b, "\x49\x49\x2a\x00") || HasPrefix(b, "\x4D\x4D\x00\x2a"))
-	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
-		return "TIFF image data"
-	},
-}
-
-var matcherMsi = fileMatcher{
-	name:   "msi",
-	minLen: 32,
-	mime:   "application/x-msi",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return looksLikeMsi(b)
-	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
-		return "Microsoft Installer (MSI)"
+			(hasPrefix(b, "\x49\x49\x2a\x00") || hasPrefix(b, "\x4D\x4D\x00\x2a"))
 	},
+	details: describeTIFF,
 }
 
 var matcherMsAccess = fileMatcher{
 	name:   "ms-access",
 	minLen: 64,
 	mime:   "application/x-msaccess",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		if lenb < 64 || !HasPrefix(b, "\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1") {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		if lenb < 64 || !hasPrefix(b, "\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1") {
 			return false
 		}
 		end := lenb
@@ -143,49 +96,29 @@ var matcherMsAccess = fileMatcher{
 		h := b[:end]
 		return bytes.Contains(h, []byte("Standard Jet DB")) || bytes.Contains(h, []byte("Standard ACE DB"))
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Microsoft Access database"
 	},
 }
 
-var matcherMsg = fileMatcher{
-	name:   "msg",
-	minLen: 64,
-	mime:   "application/vnd.ms-outlook",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		if lenb < 64 || !HasPrefix(b, "\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1") {
-			return false
-		}
-		hasProps := sampleContainsASCIIOrUTF16LE(b, "__properties_version1.0", 256*1024)
-		hasSubst := sampleContainsASCIIOrUTF16LE(b, "__substg1.0_", 256*1024)
-		hasNameID := sampleContainsASCIIOrUTF16LE(b, "__nameid_version1.0", 256*1024)
-		return hasProps && (hasSubst || hasNameID)
-	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
-		return "Microsoft Outlook MSG message"
-	},
-}
-
 var matcherOle = fileMatcher{
 	name:   "ole",
 	minLen: 33,
-	mime:   "application/x-ole-storage",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb > 32 && HasPrefix(b, "\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1")
-	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
-		return "Microsoft Office (Legacy format)"
+	mime:   "", // dynamic: Word, Excel, MSI, MSG etc., see cfbTypes
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 32 && hasPrefix(b, "\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1")
 	},
+	details: describeCFB,
 }
 
 var matcherRtf = fileMatcher{
 	name:   "rtf",
 	minLen: 33,
 	mime:   "application/rtf",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb > 32 && HasPrefix(b, "\x7B\x5C\x72\x74\x66\x31")
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 32 && hasPrefix(b, "\x7B\x5C\x72\x74\x66\x31")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Rich Text Format"
 	},
 }
@@ -194,7 +127,7 @@ var matcherPostScript = fileMatcher{
 	name:   "postscript",
 	minLen: 4,
 	mime:   "application/postscript",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		if lenb < 4 {
 			return false
 		}
@@ -203,9 +136,9 @@ var matcherPostScript = fileMatcher{
 		if len(start) >= 3 && start[0] == 0xEF && start[1] == 0xBB && start[2] == 0xBF {
 			start = start[3:]
 		}
-		return HasPrefix(start, "%!PS")
+		return hasPrefix(start, "%!PS")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		end := lenb
 		if end > 4096 {
 			end = 4096
@@ -219,25 +152,27 @@ var matcherPostScript = fileMatcher{
 }
 
 var matcherHtml = fileMatcher{
-	name:   "html",
-	minLen: 5,
-	mime:   "text/html",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	name:       "html",
+	minLen:     5,
+	mime:       "text/html",
+	confidence: 50, // syntax sniffing only
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		return looksLikeHTMLDocument(b)
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "HTML document"
 	},
 }
 
 var matcherXml = fileMatcher{
-	name:   "xml",
-	minLen: 5,
-	mime:   "", // dynamic: VMXF → text/plain, plain XML → application/octet-stream
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	name:       "xml",
+	minLen:     5,
+	mime:       "", // dynamic: VMXF → text/plain, plain XML → application/octet-stream
+	confidence: 50, // syntax sniffing only
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		return looksLikeXMLDocument(b)
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		if looksLikeVMwareVMXF(b) {
 			return "VMware supplemental configuration (VMXF)"
 		}
@@ -249,10 +184,10 @@ var matcherKml = fileMatcher{
 	name:   "kml",
 	minLen: 12,
 	mime:   "application/vnd.google-earth.kml+xml",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		return looksLikeKMLDocument(b)
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "KML geospatial data"
 	},
 }
@@ -261,10 +196,10 @@ var matcherFb2 = fileMatcher{
 	name:   "fb2",
 	minLen: 12,
 	mime:   "application/fb2+xml",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		return looksLikeFB2Document(b)
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "FictionBook e-book"
 	},
 }
@@ -273,10 +208,10 @@ var matcherJnlp = fileMatcher{
 	name:   "jnlp",
 	minLen: 8,
 	mime:   "application/x-java-jnlp-file",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		return looksLikeJNLPDocument(b)
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Java Web Start JNLP file"
 	},
 }
@@ -285,7 +220,7 @@ var matcherSvg = fileMatcher{
 	name:   "svg",
 	minLen: 5,
 	mime:   "image/svg+xml",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		if lenb < 5 || !isText(b) {
 			return false
 		}
@@ -303,16 +238,17 @@ var matcherSvg = fileMatcher{
 		}
 		return bytes.Contains(h, []byte("<svg"))
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "SVG Scalable Vector Graphics image"
 	},
 }
 
 var matcherJSON = fileMatcher{
-	name:   "json",
-	minLen: 2,
-	mime:   "application/json",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	name:       "json",
+	minLen:     2,
+	mime:       "application/json",
+	confidence: 50, // syntax sniffing only
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		if lenb < 2 || !isText(b) {
 			return false
 		}
@@ -325,7 +261,7 @@ var matcherJSON = fileMatcher{
 		}
 		return json.Valid(trimmed)
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "JSON data"
 	},
 }
@@ -621,7 +557,7 @@ func decodeUTF16ToASCII(b []byte) ([]byte, bool) {
 		}
 	}
 
-	if (len(b)-start) < 4 {
+	if (len(b) - start) < 4 {
 		return nil, false
 	}
 
@@ -1,15 +1,13 @@
-package main
-
-import "os"
+package magic
 
 var matcherWoff = fileMatcher{
 	name:   "woff",
 	minLen: 4,
 	mime:   "font/woff",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb >= 4 && HasPrefix(b, "wOFF")
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && hasPrefix(b, "wOFF")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "WOFF font"
 	},
 }
@@ -18,10 +16,10 @@ var matcherWoff2 = fileMatcher{
 	name:   "woff2",
 	minLen: 4,
 	mime:   "font/woff2",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb >= 4 && HasPrefix(b, "wOF2")
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && hasPrefix(b, "wOF2")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "WOFF2 font"
 	},
 }
@@ -30,10 +28,10 @@ var matcherOtf = fileMatcher{
 	name:   "otf",
 	minLen: 4,
 	mime:   "font/otf",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb >= 4 && HasPrefix(b, "OTTO")
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && hasPrefix(b, "OTTO")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "OpenType font data"
 	},
 }
@@ -42,7 +40,7 @@ var matcherEot = fileMatcher{
 	name:   "eot",
 	minLen: 36,
 	mime:   "application/vnd.ms-fontobject",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
 		// EOT has magic 0x504C ("LP") at offset 34 (little-endian bytes 4C 50).
 		if lenb < 36 || b[34] != 0x4C || b[35] != 0x50 {
 			return false
@@ -55,7 +53,7 @@ var matcherEot = fileMatcher{
 			return false
 		}
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "Embedded OpenType font"
 	},
 }
@@ -64,10 +62,10 @@ var matcherTtf = fileMatcher{
 	name:   "ttf",
 	minLen: 13,
 	mime:   "font/ttf",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb > 12 && HasPrefix(b, "\x00\x01\x00\x00")
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 12 && hasPrefix(b, "\x00\x01\x00\x00")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "TrueType font"
 	},
 }
@@ -76,10 +74,10 @@ var matcherTtfCollection = fileMatcher{
 	name:   "ttf-collection",
 	minLen: 13,
 	mime:   "font/collection",
-	match: func(b []byte, lenb int, magic int, _ *os.File) bool {
-		return lenb > 12 && HasPrefix(b, "ttcf\x00")
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 12 && hasPrefix(b, "ttcf\x00")
 	},
-	describe: func(b []byte, lenb int, magic int, file *os.File) string {
+	describe: func(b []byte, lenb int, magic int, file *source) string {
 		return "TrueType font collection"
 	},
 }
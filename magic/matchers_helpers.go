@@ -1,30 +1,38 @@
-package main
+package magic
 
 import (
 	"bytes"
-	"os"
+	"io"
+	"math"
 )
 
-func readTail(file *os.File, n int) ([]byte, bool) {
-	if file == nil || n <= 0 {
-		return nil, false
-	}
-	info, err := file.Stat()
-	if err != nil {
-		return nil, false
-	}
-	if info.Size() < int64(n) {
+func readTail(file *source, n int) ([]byte, bool) {
+	if file == nil || n <= 0 || file.size < int64(n) {
 		return nil, false
 	}
 	buf := make([]byte, n)
-	_, err = file.ReadAt(buf, info.Size()-int64(n))
-	if err != nil {
+	if _, err := file.ReadAt(buf, file.size-int64(n)); err != nil && err != io.EOF {
 		return nil, false
 	}
 	return buf, true
 }
 
-func hasDmgTrailer(file *os.File) bool {
+// readAt is readRegion for offsets and lengths taken from file headers: it
+// rejects negative values, and regions whose end does not fit in an int,
+// instead of counting from the end or wrapping.
+func readAt(b []byte, file *source, off, n int) ([]byte, bool) {
+	if off < 0 || n < 0 || off > math.MaxInt-n {
+		return nil, false
+	}
+	return readRegion(b, file, int64(off), n)
+}
+
+// reader returns a fresh sequential reader over the whole input.
+func (s *source) reader() *io.SectionReader {
+	return io.NewSectionReader(s, 0, s.size)
+}
+
+func hasDmgTrailer(file *source) bool {
 	buf, ok := readTail(file, 512)
 	if !ok {
 		return false
@@ -32,7 +40,7 @@ func hasDmgTrailer(file *os.File) bool {
 	return bytes.HasPrefix(buf, []byte("koly"))
 }
 
-func hasParquetFooter(file *os.File) bool {
+func hasParquetFooter(file *source) bool {
 	buf, ok := readTail(file, 4)
 	if !ok {
 		return false
@@ -40,7 +48,7 @@ func hasParquetFooter(file *os.File) bool {
 	return bytes.Equal(buf, []byte("PAR1"))
 }
 
-func hasArrowFooter(file *os.File) bool {
+func hasArrowFooter(file *source) bool {
 	buf, ok := readTail(file, 6)
 	if !ok {
 		return false
@@ -210,7 +218,7 @@ func looksLikeMsi(b []byte) bool {
 	if len(b) < 32 {
 		return false
 	}
-	if !HasPrefix(b, "\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1") {
+	if !hasPrefix(b, "\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1") {
 		return false
 	}
 	if !bytes.Equal(b[8:24], make([]byte, 16)) {
@@ -229,3 +237,37 @@ func looksLikeMsi(b []byte) bool {
 	}
 	return false
 }
+
+func hasPrefix(s []byte, prefix string) bool {
+	return len(s) >= len(prefix) && equal(s[:len(prefix)], prefix)
+}
+
+func equal(a []byte, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, v := range []byte(b) {
+		if v != a[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func peekLe(c []byte, size int) int {
+	ret := int64(0)
+
+	for i := 0; i < size; i++ {
+		ret |= int64(c[i]) << uint8(i*8)
+	}
+	return int(ret)
+}
+
+func peekBe(c []byte, size int) int {
+	ret := int64(0)
+
+	for i := 0; i < size; i++ {
+		ret = (ret << 8) | (int64(c[i]) & 0xff)
+	}
+	return int(ret)
+}
@@ -0,0 +1,626 @@
+package magic
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+var matcherPng = fileMatcher{
+	name:   "png",
+	minLen: 29,
+	mime:   "image/png",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 28 && hasPrefix(b, "\x89PNG\x0d\x0a\x1a\x0a")
+	},
+	details: describePNG,
+}
+
+func describePNG(b []byte, _ *source) (string, map[string]any) {
+	if len(b) < 29 {
+		return "PNG image data", nil
+	}
+	im := imageInfo{width: peekBe(b[16:], 4), height: peekBe(b[20:], 4), depth: int(b[24])}
+	if im.width <= 0 || im.height <= 0 {
+		return "PNG image data", nil
+	}
+	colorType := int(b[25])
+	interlaceStr := "non-interlaced"
+	if b[28] == 1 {
+		interlaceStr = "interlaced"
+	}
+	colorName := map[int]string{
+		0: "grayscale", 2: "RGB", 3: "colormap",
+		4: "grayscale, alpha", 6: "RGBA",
+	}[colorType]
+	if colorName == "" {
+		colorName = "unknown"
+	}
+	im.color = strings.ReplaceAll(colorName, ", ", "+")
+	return fmt.Sprintf("PNG image data, %d x %d, %d-bit/color %s, %s",
+		im.width, im.height, im.depth, colorName, interlaceStr), im.fields()
+}
+
+var matcherGif = fileMatcher{
+	name:   "gif",
+	minLen: 17,
+	mime:   "image/gif",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 16 && (hasPrefix(b, "GIF87a") || hasPrefix(b, "GIF89a"))
+	},
+	details: describeGIF,
+}
+
+var matcherJpeg = fileMatcher{
+	name:   "jpeg",
+	minLen: 33,
+	mime:   "image/jpeg",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 32 && hasPrefix(b, "\xff\xd8")
+	},
+	details: describeJPEG,
+}
+
+var matcherDds = fileMatcher{
+	name:   "dds",
+	minLen: 4,
+	mime:   "image/vnd-ms.dds",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && hasPrefix(b, "DDS ")
+	},
+	details: describeDDS,
+}
+
+var matcherExr = fileMatcher{
+	name:   "exr",
+	minLen: 4,
+	mime:   "image/x-exr",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && hasPrefix(b, "\x76\x2F\x31\x01")
+	},
+	details: imageDetails("OpenEXR image data", exrImage),
+}
+
+var matcherHdr = fileMatcher{
+	name:   "hdr",
+	minLen: 6,
+	mime:   "image/vnd.radiance",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 6 && (hasPrefix(b, "#?RADIANCE") || hasPrefix(b, "#?RGBE"))
+	},
+	details: imageDetails("Radiance HDR image data", hdrImage),
+}
+
+var matcherIcns = fileMatcher{
+	name:   "icns",
+	minLen: 8,
+	mime:   "image/icns",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 8 && hasPrefix(b, "icns")
+	},
+	details: imageDetails("Apple icon image", icnsImage),
+}
+
+var matcherTga = fileMatcher{
+	name:       "tga",
+	minLen:     18,
+	mime:       "image/x-tga",
+	confidence: 40, // footer is optional in TGA 1.0
+	match: func(b []byte, lenb int, magic int, file *source) bool {
+		footer := []byte("TRUEVISION-XFILE.\x00")
+		if lenb >= 26 && bytes.HasSuffix(b, footer) {
+			return true
+		}
+		tail, ok := readTail(file, 18)
+		return ok && bytes.Equal(tail, footer)
+	},
+	details: imageDetails("Targa image data", tgaImage),
+}
+
+var matcherCr2 = fileMatcher{
+	name:   "cr2",
+	minLen: 12,
+	mime:   "image/x-canon-cr2",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 12 && isTiffLike(b) && equal(b[8:10], "CR")
+	},
+	details: imageDetails("Canon CR2 raw image data", rawImage),
+}
+
+var matcherNef = fileMatcher{
+	name:   "nef",
+	minLen: 12,
+	mime:   "image/x-nikon-nef",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return isTiffLike(b) && sampleContains(b, "Nikon", 8192)
+	},
+	details: imageDetails("Nikon NEF raw image data", rawImage),
+}
+
+var matcherArw = fileMatcher{
+	name:   "arw",
+	minLen: 12,
+	mime:   "image/x-sony-arw",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return isTiffLike(b) && sampleContains(b, "SONY", 8192)
+	},
+	details: imageDetails("Sony ARW raw image data", rawImage),
+}
+
+var matcherRaf = fileMatcher{
+	name:   "raf",
+	minLen: 15,
+	mime:   "image/x-fuji-raf",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 15 && hasPrefix(b, "FUJIFILMCCD-RAW")
+	},
+	details: imageDetails("Fuji RAF raw image data", rafImage),
+}
+
+var matcherOrf = fileMatcher{
+	name:   "orf",
+	minLen: 4,
+	mime:   "image/x-olympus-orf",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && (hasPrefix(b, "\x49\x49\x52\x4F") || hasPrefix(b, "\x4D\x4D\x4F\x52"))
+	},
+	details: imageDetails("Olympus ORF raw image data", rawImage),
+}
+
+var matcherRw2 = fileMatcher{
+	name:   "rw2",
+	minLen: 4,
+	mime:   "image/x-panasonic-rw2",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && (hasPrefix(b, "\x49\x49\x55\x00") || hasPrefix(b, "\x4D\x4D\x00\x55"))
+	},
+	details: imageDetails("Panasonic RW2 raw image data", rawImage),
+}
+
+var matcherDng = fileMatcher{
+	name:   "dng",
+	minLen: 12,
+	mime:   "image/x-adobe-dng",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return isTiffLike(b) && sampleContains(b, "DNGVersion", 8192)
+	},
+	details: imageDetails("Adobe DNG raw image data", rawImage),
+}
+
+var matcherCr3 = fileMatcher{
+	name:   "cr3",
+	minLen: 12,
+	mime:   "image/x-canon-cr3",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return isCr3Like(b)
+	},
+	details: imageDetails("Canon CR3 raw image data", cr3Image),
+}
+
+var matcherFlv = fileMatcher{
+	name:   "flv",
+	minLen: 3,
+	mime:   "video/x-flv",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 3 && hasPrefix(b, "FLV")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "FLV video file"
+	},
+}
+
+var matcherMatroska = fileMatcher{
+	name:   "matroska",
+	minLen: 4,
+	mime:   "video/x-matroska",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && hasPrefix(b, "\x1A\x45\xDF\xA3") && bytes.Contains(b, []byte("matroska"))
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "Matroska video file"
+	},
+}
+
+var matcherWebm = fileMatcher{
+	name:   "webm",
+	minLen: 4,
+	mime:   "video/webm",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 4 && hasPrefix(b, "\x1A\x45\xDF\xA3") && bytes.Contains(b, []byte("webm"))
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "WebM video file"
+	},
+}
+
+var matcherOgg = fileMatcher{
+	name:   "ogg",
+	minLen: 37,
+	mime:   "audio/ogg",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 36 && hasPrefix(b, "OggS\x00\x02")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return oggSubtype(b)
+	},
+}
+
+var matcherAiff = fileMatcher{
+	name:   "aiff",
+	minLen: 12,
+	mime:   "audio/aiff",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 12 && hasPrefix(b, "FORM") &&
+			(equal(b[8:12], "AIFF") || equal(b[8:12], "AIFC"))
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return describeAIFF(b)
+	},
+}
+
+func describeAIFF(b []byte) string {
+	isAIFC := len(b) >= 12 && equal(b[8:12], "AIFC")
+	base := "AIFF audio data"
+	if isAIFC {
+		base = "AIFF-C audio data"
+	}
+	// COMM chunk is typically first, starting at byte 12:
+	// "COMM"(4) + size(4) + channels(2) + frames(4) + sampleSize(2) + sampleRate(10)
+	if len(b) >= 38 && equal(b[12:16], "COMM") {
+		channels := peekBe(b[20:], 2)
+		sampleSize := peekBe(b[26:], 2)
+		sampleRate := parse80BitExtended(b[28:])
+		if sampleRate > 0 && channels > 0 && sampleSize > 0 {
+			chStr := map[int]string{1: "mono", 2: "stereo"}[channels]
+			if chStr == "" {
+				chStr = fmt.Sprintf("%d channels", channels)
+			}
+			return fmt.Sprintf("%s, %d Hz, %s, %d-bit", base, sampleRate, chStr, sampleSize)
+		}
+	}
+	return base
+}
+
+var matcherAac = fileMatcher{
+	name:       "aac",
+	minLen:     2,
+	mime:       "audio/aac",
+	confidence: 40, // 12-bit frame sync
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		if lenb < 2 {
+			return false
+		}
+		// ADTS sync word: 0xFF followed by 0xF0 or 0xF1 (MPEG-4 AAC) or 0xF8/0xF9 (MPEG-2 AAC).
+		if b[0] != 0xFF {
+			return false
+		}
+		return b[1] == 0xF1 || b[1] == 0xF9
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "AAC audio data"
+	},
+}
+
+var matcherWav = fileMatcher{
+	name:   "wav",
+	minLen: 33,
+	mime:   "audio/wav",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 32 && hasPrefix(b, "RIF") && equal(b[8:16], "WAVEfmt ")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return describeWAV(b)
+	},
+}
+
+func describeWAV(b []byte) string {
+	if len(b) < 36 {
+		return "WAV audio"
+	}
+	audioFmt := peekLe(b[20:], 2)
+	channels := peekLe(b[22:], 2)
+	sampleRate := peekLe(b[24:], 4)
+	bitsPerSample := peekLe(b[34:], 2)
+	if sampleRate <= 0 || channels <= 0 || bitsPerSample <= 0 {
+		return "WAV audio"
+	}
+	fmtName := map[int]string{
+		1: "PCM", 3: "IEEE float", 6: "a-law", 7: "mu-law", 0xFFFE: "extensible",
+	}[audioFmt]
+	if fmtName == "" {
+		fmtName = fmt.Sprintf("format 0x%04X", audioFmt)
+	}
+	chStr := map[int]string{1: "mono", 2: "stereo"}[channels]
+	if chStr == "" {
+		chStr = fmt.Sprintf("%d channels", channels)
+	}
+	return fmt.Sprintf("WAV audio, %d Hz, %s, %d-bit %s", sampleRate, chStr, bitsPerSample, fmtName)
+}
+
+var matcherMp3 = fileMatcher{
+	name:       "mp3",
+	minLen:     17,
+	mime:       "audio/mpeg",
+	confidence: 40, // frame sync without ID3 is common in noise
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 16 &&
+			(hasPrefix(b, "ID3") || hasPrefix(b, "\xff\xfb") || hasPrefix(b, "\xff\xf3") || hasPrefix(b, "\xff\xf2"))
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "MP3 audio file"
+	},
+}
+
+var matcherHeif = fileMatcher{
+	name:   "heif",
+	minLen: 12,
+	mime:   "image/heif",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return isHeifFamily(b)
+	},
+	details: imageDetails("HEIF image", heifImage),
+}
+
+var matcherAvif = fileMatcher{
+	name:   "avif",
+	minLen: 12,
+	mime:   "image/avif",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return isAvifLike(b)
+	},
+	details: imageDetails("AVIF image", heifImage),
+}
+
+var matcherJxl = fileMatcher{
+	name:   "jxl",
+	minLen: 2,
+	mime:   "image/jxl",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		// JPEG XL codestream signature.
+		if lenb >= 2 && hasPrefix(b, "\xFF\x0A") {
+			return true
+		}
+		// JPEG XL container signature.
+		return lenb >= 12 && hasPrefix(b, "\x00\x00\x00\x0C\x4A\x58\x4C\x20\x0D\x0A\x87\x0A")
+	},
+	details: imageDetails("JPEG XL image data", jxlImage),
+}
+
+var matcherJpeg2000 = fileMatcher{
+	name:   "jpeg2000",
+	minLen: 4,
+	mime:   "image/jp2",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		// JPEG 2000 codestream signature.
+		if lenb >= 4 && hasPrefix(b, "\xFF\x4F\xFF\x51") {
+			return true
+		}
+		// JP2 signature box.
+		return lenb >= 12 && hasPrefix(b, "\x00\x00\x00\x0C\x6A\x50\x20\x20\x0D\x0A\x87\x0A")
+	},
+	details: imageDetails("JPEG 2000 image data", jp2Image),
+}
+
+var matcherM4a = fileMatcher{
+	name:   "m4a",
+	minLen: 12,
+	mime:   "audio/mp4",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return isM4aLike(b)
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "M4A audio"
+	},
+}
+
+var matcherQuickTime = fileMatcher{
+	name:   "quicktime",
+	minLen: 12,
+	mime:   "video/quicktime",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return isQuickTimeLike(b)
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "QuickTime movie file"
+	},
+}
+
+var matcher3gpp = fileMatcher{
+	name:   "3gpp",
+	minLen: 12,
+	mime:   "", // dynamic: "video/3gpp" or "video/3gpp2"
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return is3gpLike(b)
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		if hasFtypBrand(b, "3g2") {
+			return "3GPP2 video file"
+		}
+		return "3GPP video file"
+	},
+}
+
+var matcherM4v = fileMatcher{
+	name:   "m4v",
+	minLen: 12,
+	mime:   "video/x-m4v",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return isM4vLike(b)
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "M4V video file"
+	},
+}
+
+var matcherMp4 = fileMatcher{
+	name:   "mp4",
+	minLen: 12,
+	mime:   "video/mp4",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return isMp4Like(b)
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "MP4 video file"
+	},
+}
+
+var matcherMpegPs = fileMatcher{
+	name:       "mpeg-ps",
+	minLen:     4,
+	mime:       "video/mpeg",
+	confidence: 40, // pack start code only
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		// MPEG Program Stream pack header (commonly .mpg/.mpeg/.vob).
+		return lenb >= 4 && hasPrefix(b, "\x00\x00\x01\xBA")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "MPEG video file"
+	},
+}
+
+var matcherMpegTs = fileMatcher{
+	name:       "mpeg-ts",
+	minLen:     377,
+	mime:       "video/mp2t",
+	confidence: 40, // sync bytes only
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return isMpegTsLike(b)
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "MPEG-TS video file"
+	},
+}
+
+var matcherIco = fileMatcher{
+	name:   "ico",
+	minLen: 17,
+	mime:   "image/x-icon",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 16 && hasPrefix(b, "\x00\x00\x01\x00")
+	},
+	details: describeIconDir("MS Windows icon resource"),
+}
+
+var matcherCur = fileMatcher{
+	name:   "cur",
+	minLen: 6,
+	mime:   "image/x-icon",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 6 && hasPrefix(b, "\x00\x00\x02\x00") && peekLe(b[4:], 2) > 0
+	},
+	details: describeIconDir("MS Windows cursor resource"),
+}
+
+var matcherFlac = fileMatcher{
+	name:   "flac",
+	minLen: 17,
+	mime:   "audio/flac",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 16 && hasPrefix(b, "\x66\x4C\x61\x43")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return describeFLAC(b)
+	},
+}
+
+func describeFLAC(b []byte) string {
+	// STREAMINFO metadata block starts at byte 4 (after "fLaC" marker).
+	// Byte 4: last-block flag (1 bit) + block type (7 bits); STREAMINFO = type 0.
+	// Bytes 5-7: block length. STREAMINFO data starts at byte 8.
+	// Sample rate: 20 bits starting at STREAMINFO byte 10 (file byte 18).
+	// Channels-1: 3 bits; bits_per_sample-1: 5 bits — packed in file bytes 20-21.
+	if len(b) < 22 {
+		return "FLAC audio format"
+	}
+	sampleRate := (int(b[18]) << 12) | (int(b[19]) << 4) | (int(b[20]) >> 4)
+	channels := ((int(b[20]) >> 1) & 0x07) + 1
+	bitsPerSample := ((int(b[20])&0x01)<<4 | int(b[21])>>4) + 1
+	if sampleRate <= 0 {
+		return "FLAC audio format"
+	}
+	chStr := map[int]string{1: "mono", 2: "stereo"}[channels]
+	if chStr == "" {
+		chStr = fmt.Sprintf("%d channels", channels)
+	}
+	return fmt.Sprintf("FLAC audio format, %d Hz, %s, %d-bit", sampleRate, chStr, bitsPerSample)
+}
+
+var matcherMidi = fileMatcher{
+	name:   "midi",
+	minLen: 14,
+	mime:   "audio/midi",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		if lenb < 14 || !hasPrefix(b, "MThd") {
+			return false
+		}
+		headerLen := peekBe(b[4:], 4)
+		if headerLen != 6 {
+			return false
+		}
+		format := peekBe(b[8:], 2)
+		tracks := peekBe(b[10:], 2)
+		return format >= 0 && format <= 2 && tracks > 0
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		format := peekBe(b[8:], 2)
+		tracks := peekBe(b[10:], 2)
+		division := peekBe(b[12:], 2)
+
+		trackWord := "track"
+		if tracks != 1 {
+			trackWord = "tracks"
+		}
+
+		if division&0x8000 == 0 {
+			return fmt.Sprintf("Standard MIDI data (format %d) using %d %s at 1/%d", format, tracks, trackWord, division)
+		}
+
+		fps := 256 - ((division >> 8) & 0xFF)
+		ticksPerFrame := division & 0xFF
+		return fmt.Sprintf("Standard MIDI data (format %d) using %d %s at %d fps, %d ticks/frame", format, tracks, trackWord, fps, ticksPerFrame)
+	},
+}
+
+var matcherPsd = fileMatcher{
+	name:   "psd",
+	minLen: 17,
+	mime:   "image/vnd.adobe.photoshop",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 16 && hasPrefix(b, "\x38\x42\x50\x53")
+	},
+	details: imageDetails("Photoshop document", psdImage),
+}
+
+var matcherAvi = fileMatcher{
+	name:   "avi",
+	minLen: 33,
+	mime:   "video/x-msvideo",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 32 && hasPrefix(b, "RIF") && equal(b[8:11], "AVI")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "AVI file"
+	},
+}
+
+var matcherAsf = fileMatcher{
+	name:   "asf",
+	minLen: 16,
+	mime:   "video/x-ms-asf",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb >= 16 && hasPrefix(b, "\x30\x26\xB2\x75\x8E\x66\xCF\x11\xA6\xD9\x00\xAA\x00\x62\xCE\x6C")
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "ASF media file"
+	},
+}
+
+var matcherWebp = fileMatcher{
+	name:   "webp",
+	minLen: 33,
+	mime:   "image/webp",
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return lenb > 32 && hasPrefix(b, "RIF") && equal(b[8:12], "WEBP")
+	},
+	details: describeWebP,
+}
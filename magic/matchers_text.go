@@ -0,0 +1,27 @@
+package magic
+
+var matcherText = fileMatcher{
+	name:       "text",
+	minLen:     1,
+	mime:       "", // dynamic: varies by sub-type (text/plain, text/markdown, application/mbox, etc.)
+	confidence: 10, // any decodable text
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return isText(b)
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return describeText(b)
+	},
+}
+
+var matcherDataFallback = fileMatcher{
+	name:       "data",
+	minLen:     1,
+	mime:       "application/octet-stream",
+	confidence: 1, // matches everything that is not text
+	match: func(b []byte, lenb int, magic int, _ *source) bool {
+		return !isText(b)
+	},
+	describe: func(b []byte, lenb int, magic int, file *source) string {
+		return "data"
+	},
+}
@@ -0,0 +1,453 @@
+package magic
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// Member is one entry of an archive visited by WalkMembers.
+type Member struct {
+	// Path is the entry name inside the archive. Entries of nested archives
+	// are joined with "!/", as in "lib/inner.zip!/README".
+	Path string
+	Size int64 // uncompressed size as recorded by the archive
+
+	// Result classifies the entry's leading bytes. Directories, links and
+	// device nodes get a description like the CLI's ("directory").
+	Result Result
+
+	// Skipped says why the entry's content could not be read, such as
+	// "encrypted" or "RAR compressed". Result is empty when it is set.
+	Skipped string
+}
+
+// ErrNotArchive is returned by WalkMembers for input that is not a container
+// it can list.
+var ErrNotArchive = errors.New("not a supported archive")
+
+const (
+	// maxMemberBuffer is the largest member read whole, so its trailers can
+	// be inspected and nested archives listed. Larger members are classified
+	// from their leading MaxBytesToRead bytes.
+	maxMemberBuffer = 64 << 20
+
+	// maxMembersRead bounds the bytes buffered for one WalkMembers call, so
+	// an archive of archives cannot keep us busy.
+	maxMembersRead = 1 << 30
+)
+
+// WalkMembers lists the members of the zip, tar, ar, cpio, 7z or RAR archive
+// in r, which holds size bytes, calling fn with each in archive order. Tar
+// and cpio archives inside gzip, bzip2, xz, zstd, lz4 or zlib streams are
+// listed too. Members that are archives themselves are walked up to maxDepth
+// levels down (0 lists the outer archive only). An error from fn stops the
+// walk and is returned.
+func WalkMembers(r io.ReaderAt, size int64, opts Options, maxDepth int, fn func(Member) error) error {
+	res, err := Detect(r, size, Options{Rules: opts.Rules})
+	if err != nil {
+		return err
+	}
+	opts.KeepGoing = false
+	w := &memberWalker{opts: opts, maxDepth: maxDepth, fn: fn, budget: maxMembersRead}
+	return w.walk(&source{ReaderAt: r, size: size}, res.Matcher, "", 0)
+}
+
+type memberWalker struct {
+	opts     Options
+	maxDepth int
+	fn       func(Member) error
+	budget   int64 // bytes left to buffer
+	stopped  error // fn's error, which ends the walk
+}
+
+func (w *memberWalker) walk(src *source, matcher, prefix string, depth int) error {
+	switch matcher {
+	case "zip":
+		return w.walkZip(src, prefix, depth)
+	case "tar":
+		return w.walkTar(src.reader(), prefix, depth)
+	case "ar":
+		return w.walkAr(src.reader(), prefix, depth)
+	case "cpio":
+		return w.walkCpio(src.reader(), prefix, depth)
+	case "7zip":
+		return w.walk7z(src, prefix, depth)
+	case "rar":
+		return w.walkRar(src, prefix, depth)
+	}
+	if open, ok := decompressors[matcher]; ok {
+		return w.walkCompressed(open, src, prefix, depth)
+	}
+	return ErrNotArchive
+}
+
+func (w *memberWalker) emit(m Member) error {
+	if err := w.fn(m); err != nil {
+		w.stopped = err
+		return err
+	}
+	return nil
+}
+
+func (w *memberWalker) skip(path string, size int64, reason string) error {
+	return w.emit(Member{Path: path, Size: size, Skipped: reason})
+}
+
+// special reports an entry without content of its own.
+func (w *memberWalker) special(path string, size int64, desc string) error {
+	return w.emit(Member{Path: path, Size: size, Result: Result{Description: desc, MIME: dynamicMIME(desc)}})
+}
+
+// entry reports a member by its file mode, classifying regular files.
+func (w *memberWalker) entry(path string, size int64, mode fs.FileMode, link string, r io.Reader, depth int) error {
+	switch {
+	case mode.IsDir():
+		return w.special(path, size, "directory")
+	case mode&fs.ModeSymlink != 0:
+		if link == "" {
+			// cpio and zip store the target as the entry's data.
+			target, err := io.ReadAll(io.LimitReader(r, 4096))
+			if err != nil {
+				return w.skip(path, size, err.Error())
+			}
+			link = string(target)
+		}
+		return w.special(path, size, "symbolic link to "+link)
+	case mode&fs.ModeCharDevice != 0:
+		return w.special(path, size, "character special device")
+	case mode&fs.ModeDevice != 0:
+		return w.special(path, size, "block special device")
+	case mode&fs.ModeNamedPipe != 0:
+		return w.special(path, size, "fifo")
+	case mode&fs.ModeSocket != 0:
+		return w.special(path, size, "socket")
+	}
+	return w.visit(path, size, r, depth)
+}
+
+// visit classifies a regular member and walks into it when it is an archive
+// that was read whole.
+func (w *memberWalker) visit(path string, size int64, r io.Reader, depth int) error {
+	limit := int64(MaxBytesToRead)
+	if size <= maxMemberBuffer && size <= w.budget {
+		limit = size
+	}
+	data, err := io.ReadAll(io.LimitReader(r, limit))
+	w.budget -= int64(len(data))
+	if err != nil {
+		return w.skip(path, size, err.Error())
+	}
+
+	opts := w.opts
+	opts.Filename = path
+	src := &source{ReaderAt: bytes.NewReader(data), size: int64(len(data))}
+	res, err := Detect(src, src.size, opts)
+	if err != nil {
+		return w.skip(path, size, err.Error())
+	}
+	if err := w.emit(Member{Path: path, Size: size, Result: res}); err != nil {
+		return err
+	}
+
+	if depth >= w.maxDepth || int64(len(data)) != size {
+		return nil
+	}
+	err = w.walk(src, res.Matcher, path+"!/", depth+1)
+	switch {
+	case w.stopped != nil:
+		return w.stopped
+	case err == nil || err == ErrNotArchive:
+		return nil
+	}
+	// A damaged nested archive is reported, not fatal to its parent.
+	return w.skip(path+"!/", size, err.Error())
+}
+
+func (w *memberWalker) walkZip(src *source, prefix string, depth int) error {
+	zr, err := zip.NewReader(src, src.size)
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		path := prefix + f.Name
+		size := int64(f.UncompressedSize64)
+		mode := f.Mode()
+		if mode.IsDir() {
+			if err := w.special(path, size, "directory"); err != nil {
+				return err
+			}
+			continue
+		}
+		if f.Flags&0x1 != 0 {
+			if err := w.skip(path, size, "encrypted"); err != nil {
+				return err
+			}
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			if err := w.skip(path, size, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		err = w.entry(path, size, mode, "", rc, depth)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *memberWalker) walkTar(r io.Reader, prefix string, depth int) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		path := prefix + hdr.Name
+		if hdr.Typeflag == tar.TypeLink {
+			err = w.special(path, 0, "hard link to "+hdr.Linkname)
+		} else {
+			err = w.entry(path, hdr.Size, hdr.FileInfo().Mode(), hdr.Linkname, tr, depth)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// walkCompressed lists a tar or cpio archive inside a compressed stream.
+func (w *memberWalker) walkCompressed(open func(io.Reader) (io.Reader, error), src *source, prefix string, depth int) error {
+	r, err := open(src.reader())
+	if err != nil {
+		return ErrNotArchive
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+	br := bufio.NewReaderSize(r, MaxBytesToRead)
+	head, _ := br.Peek(MaxBytesToRead)
+	payload, err := detectFromBytes(head, Options{Rules: w.opts.Rules}, nil)
+	if err != nil {
+		return ErrNotArchive
+	}
+	switch payload.Matcher {
+	case "tar":
+		return w.walkTar(br, prefix, depth)
+	case "cpio":
+		return w.walkCpio(br, prefix, depth)
+	}
+	return ErrNotArchive
+}
+
+func (w *memberWalker) walkAr(r io.Reader, prefix string, depth int) error {
+	br := bufio.NewReader(r)
+	if _, err := br.Discard(8); err != nil {
+		return err
+	}
+	var longNames []byte
+	hdr := make([]byte, 60)
+	for {
+		if _, err := io.ReadFull(br, hdr); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if hdr[58] != '`' || hdr[59] != '\n' {
+			return errors.New("corrupt ar member header")
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(string(hdr[48:58])), 10, 64)
+		if err != nil || size < 0 {
+			return errors.New("corrupt ar member size")
+		}
+		body := io.LimitReader(br, size)
+		dataSize := size
+
+		name := strings.TrimRight(string(hdr[:16]), " ")
+		switch {
+		case name == "//":
+			// GNU long name table, referenced as "/<offset>".
+			longNames, err = io.ReadAll(io.LimitReader(body, 1<<20))
+			if err != nil {
+				return err
+			}
+			name = ""
+		case name == "/" || name == "/SYM64/" || strings.HasPrefix(name, "__.SYMDEF"):
+			name = "" // symbol table
+		case strings.HasPrefix(name, "#1/"):
+			// BSD: the name follows the header and counts towards its size.
+			n, err := strconv.Atoi(name[3:])
+			if err != nil || n < 0 || int64(n) > size || n > 4096 {
+				return errors.New("corrupt ar member name")
+			}
+			buf := make([]byte, n)
+			if _, err := io.ReadFull(body, buf); err != nil {
+				return err
+			}
+			name = string(bytes.TrimRight(buf, "\x00"))
+			dataSize -= int64(n)
+		case len(name) > 1 && name[0] == '/':
+			off, err := strconv.Atoi(name[1:])
+			if err != nil || off < 0 || off >= len(longNames) {
+				return errors.New("corrupt ar long name reference")
+			}
+			name = string(longNames[off:])
+			if end := strings.Index(name, "/\n"); end >= 0 {
+				name = name[:end]
+			}
+		default:
+			name = strings.TrimSuffix(name, "/")
+		}
+
+		if name != "" {
+			if err := w.visit(prefix+name, dataSize, body, depth); err != nil {
+				return err
+			}
+		}
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			return err
+		}
+		if size%2 != 0 {
+			br.Discard(1)
+		}
+	}
+}
+
+// cpioHeader is the part of a cpio entry header WalkMembers needs.
+type cpioHeader struct {
+	name    string
+	mode    uint64 // unix st_mode
+	size    int64
+	dataPad int // alignment after the data
+}
+
+func (w *memberWalker) walkCpio(r io.Reader, prefix string, depth int) error {
+	br := bufio.NewReader(r)
+	for {
+		h, err := readCpioHeader(br)
+		if err != nil {
+			return err
+		}
+		if h.name == "TRAILER!!!" {
+			return nil
+		}
+		body := io.LimitReader(br, h.size)
+		if err := w.entry(prefix+h.name, h.size, unixFileMode(h.mode), "", body, depth); err != nil {
+			return err
+		}
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			return err
+		}
+		if _, err := br.Discard(h.dataPad); err != nil {
+			return err
+		}
+	}
+}
+
+// readCpioHeader reads a newc, crc, odc or binary cpio header and the name
+// that follows it.
+func readCpioHeader(br *bufio.Reader) (cpioHeader, error) {
+	errCorrupt := errors.New("corrupt cpio header")
+	magic, err := br.Peek(6)
+	if err != nil {
+		return cpioHeader{}, err
+	}
+
+	var h cpioHeader
+	var nameSize, namePad int
+	switch {
+	case hasPrefix(magic, "070701") || hasPrefix(magic, "070702"):
+		hdr := make([]byte, 110)
+		if _, err := io.ReadFull(br, hdr); err != nil {
+			return h, err
+		}
+		field := func(i int) uint64 {
+			v, perr := strconv.ParseUint(string(hdr[6+8*i:14+8*i]), 16, 32)
+			if perr != nil {
+				err = errCorrupt
+			}
+			return v
+		}
+		h.mode = field(1)
+		h.size = int64(field(6))
+		nameSize = int(field(11))
+		namePad = (4 - (110+nameSize)%4) % 4
+		h.dataPad = int((4 - h.size%4) % 4)
+	case hasPrefix(magic, "070707"):
+		hdr := make([]byte, 76)
+		if _, err := io.ReadFull(br, hdr); err != nil {
+			return h, err
+		}
+		field := func(from, to int) uint64 {
+			v, perr := strconv.ParseUint(string(hdr[from:to]), 8, 64)
+			if perr != nil {
+				err = errCorrupt
+			}
+			return v
+		}
+		h.mode = field(18, 24)
+		nameSize = int(field(59, 65))
+		h.size = int64(field(65, 76))
+	case hasPrefix(magic, "\xC7\x71") || hasPrefix(magic, "\x71\xC7"):
+		hdr := make([]byte, 26)
+		if _, err := io.ReadFull(br, hdr); err != nil {
+			return h, err
+		}
+		var order binary.ByteOrder = binary.LittleEndian
+		if hdr[0] == 0x71 {
+			order = binary.BigEndian
+		}
+		h.mode = uint64(order.Uint16(hdr[6:]))
+		nameSize = int(order.Uint16(hdr[20:]))
+		h.size = int64(order.Uint16(hdr[22:]))<<16 | int64(order.Uint16(hdr[24:]))
+		namePad = nameSize % 2
+		h.dataPad = int(h.size % 2)
+	default:
+		return h, errCorrupt
+	}
+	if err != nil {
+		return h, err
+	}
+	if nameSize <= 0 || nameSize > 4096 || h.size < 0 {
+		return h, errCorrupt
+	}
+	name := make([]byte, nameSize+namePad)
+	if _, err := io.ReadFull(br, name); err != nil {
+		return h, err
+	}
+	h.name = string(bytes.TrimRight(name[:nameSize], "\x00"))
+	return h, nil
+}
+
+// unixFileMode converts the type bits of a unix st_mode.
+func unixFileMode(mode uint64) fs.FileMode {
+	switch mode & 0170000 {
+	case 0040000:
+		return fs.ModeDir
+	case 0120000:
+		return fs.ModeSymlink
+	case 0020000:
+		return fs.ModeDevice | fs.ModeCharDevice
+	case 0060000:
+		return fs.ModeDevice
+	case 0010000:
+		return fs.ModeNamedPipe
+	case 0140000:
+		return fs.ModeSocket
+	}
+	return 0
+}
@@ -0,0 +1,621 @@
+package magic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"unicode/utf16"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// 7z property IDs, from 7-Zip's DOC/7zFormat.txt.
+const (
+	szEnd                   = 0x00
+	szHeader                = 0x01
+	szArchiveProperties     = 0x02
+	szAdditionalStreamsInfo = 0x03
+	szMainStreamsInfo       = 0x04
+	szFilesInfo             = 0x05
+	szPackInfo              = 0x06
+	szUnpackInfo            = 0x07
+	szSubStreamsInfo        = 0x08
+	szSize                  = 0x09
+	szCRC                   = 0x0A
+	szFolders               = 0x0B
+	szCodersUnpackSize      = 0x0C
+	szNumUnpackStream       = 0x0D
+	szEmptyStream           = 0x0E
+	szEmptyFile             = 0x0F
+	szName                  = 0x11
+	szEncodedHeader         = 0x17
+)
+
+const (
+	// sevenZipHeaderSize is the signature header in front of the packed
+	// streams.
+	sevenZipHeaderSize = 32
+
+	// maxSevenZipHeader bounds the (decoded) header we parse.
+	maxSevenZipHeader = 64 << 20
+
+	// maxSevenZipDict bounds the LZMA dictionary we allocate.
+	maxSevenZipDict = 256 << 20
+)
+
+// szAES is the coder ID of 7-Zip's AES-256 encryption.
+var szAES = []byte{0x06, 0xF1, 0x07, 0x01}
+
+var errSevenZipCorrupt = errors.New("corrupt 7z header")
+
+type szCoder struct {
+	id     []byte
+	props  []byte
+	numIn  int
+	numOut int
+}
+
+type szFolder struct {
+	coders        []szCoder
+	bindOut       []uint64 // output streams consumed by another coder
+	numPacked     int
+	unpackSizes   []uint64 // one per coder output stream
+	unpackCRC     bool
+	numSubstreams int
+	substreams    []uint64 // member sizes, from SubStreamsInfo
+}
+
+// unpackSize is the size of the folder's final output stream.
+func (f *szFolder) unpackSize() uint64 {
+	for i, size := range f.unpackSizes {
+		if !slices.Contains(f.bindOut, uint64(i)) {
+			return size
+		}
+	}
+	return 0
+}
+
+type szStreams struct {
+	packPos   uint64
+	packSizes []uint64
+	folders   []szFolder
+}
+
+type szFile struct {
+	name      string
+	hasStream bool
+	isDir     bool
+}
+
+// szReader decodes 7z header structures. The first error sticks and makes
+// every further read return zero values.
+type szReader struct {
+	b   []byte
+	off int
+	err error
+}
+
+func (p *szReader) fail() {
+	if p.err == nil {
+		p.err = errSevenZipCorrupt
+	}
+}
+
+func (p *szReader) byte() byte {
+	if p.err != nil || p.off >= len(p.b) {
+		p.fail()
+		return 0
+	}
+	p.off++
+	return p.b[p.off-1]
+}
+
+func (p *szReader) bytes(n uint64) []byte {
+	if p.err != nil || n > uint64(len(p.b)-p.off) {
+		p.fail()
+		return nil
+	}
+	p.off += int(n)
+	return p.b[p.off-int(n) : p.off]
+}
+
+// number reads 7z's variable-length integer: the leading one bits of the
+// first byte count the little-endian bytes that follow.
+func (p *szReader) number() uint64 {
+	first := p.byte()
+	mask := byte(0x80)
+	var value uint64
+	for i := 0; i < 8; i++ {
+		if first&mask == 0 {
+			return value | (uint64(first)&(uint64(mask)-1))<<(8*i)
+		}
+		value |= uint64(p.byte()) << (8 * i)
+		mask >>= 1
+	}
+	return value
+}
+
+// count reads a number used to size a slice. Every counted item takes at
+// least one byte, so anything beyond the header is corrupt.
+func (p *szReader) count() int {
+	n := p.number()
+	if n > uint64(len(p.b)) {
+		p.fail()
+		return 0
+	}
+	return int(n)
+}
+
+func (p *szReader) bits(n int) []bool {
+	v := make([]bool, n)
+	raw := p.bytes(uint64((n + 7) / 8))
+	for i := range v {
+		if raw != nil {
+			v[i] = raw[i/8]&(0x80>>(i%8)) != 0
+		}
+	}
+	return v
+}
+
+// digests skips a CRC list and returns which entries had one.
+func (p *szReader) digests(n int) []bool {
+	var defined []bool
+	if p.byte() != 0 {
+		defined = make([]bool, n)
+		for i := range defined {
+			defined[i] = true
+		}
+	} else {
+		defined = p.bits(n)
+	}
+	for _, d := range defined {
+		if d {
+			p.bytes(4)
+		}
+	}
+	return defined
+}
+
+func (p *szReader) streamsInfo() szStreams {
+	var s szStreams
+	for p.err == nil {
+		switch p.byte() {
+		case szEnd:
+			return s
+		case szPackInfo:
+			p.packInfo(&s)
+		case szUnpackInfo:
+			p.unpackInfo(&s)
+		case szSubStreamsInfo:
+			p.subStreamsInfo(&s)
+		default:
+			p.fail()
+		}
+	}
+	return s
+}
+
+func (p *szReader) packInfo(s *szStreams) {
+	s.packPos = p.number()
+	n := p.count()
+	for p.err == nil {
+		switch p.byte() {
+		case szEnd:
+			return
+		case szSize:
+			s.packSizes = make([]uint64, n)
+			for i := range s.packSizes {
+				s.packSizes[i] = p.number()
+			}
+		case szCRC:
+			p.digests(n)
+		default:
+			p.fail()
+		}
+	}
+}
+
+func (p *szReader) unpackInfo(s *szStreams) {
+	if p.byte() != szFolders {
+		p.fail()
+		return
+	}
+	n := p.count()
+	if p.byte() != 0 {
+		p.fail() // folders stored in an additional stream
+		return
+	}
+	s.folders = make([]szFolder, n)
+	for i := range s.folders {
+		s.folders[i] = p.folder()
+	}
+	if p.byte() != szCodersUnpackSize {
+		p.fail()
+		return
+	}
+	for i := range s.folders {
+		f := &s.folders[i]
+		for j := range f.unpackSizes {
+			f.unpackSizes[j] = p.number()
+		}
+		f.numSubstreams = 1
+	}
+	for p.err == nil {
+		switch p.byte() {
+		case szEnd:
+			return
+		case szCRC:
+			for i, d := range p.digests(n) {
+				s.folders[i].unpackCRC = d
+			}
+		default:
+			p.fail()
+		}
+	}
+}
+
+func (p *szReader) folder() szFolder {
+	var f szFolder
+	numCoders := p.count()
+	totalIn, totalOut := 0, 0
+	for i := 0; i < numCoders && p.err == nil; i++ {
+		flags := p.byte()
+		c := szCoder{id: p.bytes(uint64(flags & 0x0F)), numIn: 1, numOut: 1}
+		if flags&0x10 != 0 {
+			c.numIn, c.numOut = p.count(), p.count()
+		}
+		if flags&0x20 != 0 {
+			c.props = p.bytes(p.number())
+		}
+		if flags&0x80 != 0 {
+			p.fail() // alternative methods were never written by 7-Zip
+		}
+		f.coders = append(f.coders, c)
+		totalIn += c.numIn
+		totalOut += c.numOut
+	}
+	if totalOut < 1 || totalOut > 64 || totalIn < totalOut-1 {
+		p.fail()
+		return f
+	}
+	for i := 0; i < totalOut-1; i++ {
+		p.number() // input index
+		f.bindOut = append(f.bindOut, p.number())
+	}
+	f.numPacked = totalIn - (totalOut - 1)
+	if f.numPacked > 1 {
+		for i := 0; i < f.numPacked; i++ {
+			p.number()
+		}
+	}
+	f.unpackSizes = make([]uint64, totalOut)
+	return f
+}
+
+func (p *szReader) subStreamsInfo(s *szStreams) {
+	typ := p.byte()
+	if typ == szNumUnpackStream {
+		for i := range s.folders {
+			s.folders[i].numSubstreams = p.count()
+		}
+		typ = p.byte()
+	}
+	for i := range s.folders {
+		f := &s.folders[i]
+		if f.numSubstreams == 0 {
+			continue
+		}
+		total := f.unpackSize()
+		f.substreams = make([]uint64, f.numSubstreams)
+		var sum uint64
+		for j := 0; j < f.numSubstreams-1 && typ == szSize; j++ {
+			f.substreams[j] = p.number()
+			sum += f.substreams[j]
+		}
+		if sum > total {
+			p.fail()
+			return
+		}
+		f.substreams[f.numSubstreams-1] = total - sum
+	}
+	if typ == szSize {
+		typ = p.byte()
+	}
+	for p.err == nil && typ != szEnd {
+		if typ != szCRC {
+			p.fail()
+			return
+		}
+		n := 0
+		for _, f := range s.folders {
+			if f.numSubstreams != 1 || !f.unpackCRC {
+				n += f.numSubstreams
+			}
+		}
+		p.digests(n)
+		typ = p.byte()
+	}
+}
+
+func (p *szReader) header() (szStreams, []szFile) {
+	var s szStreams
+	var files []szFile
+	for p.err == nil {
+		switch p.byte() {
+		case szEnd:
+			return s, files
+		case szArchiveProperties:
+			for p.err == nil && p.byte() != 0 {
+				p.bytes(p.number())
+			}
+		case szAdditionalStreamsInfo:
+			p.streamsInfo()
+		case szMainStreamsInfo:
+			s = p.streamsInfo()
+		case szFilesInfo:
+			files = p.filesInfo()
+		default:
+			p.fail()
+		}
+	}
+	return s, files
+}
+
+func (p *szReader) filesInfo() []szFile {
+	files := make([]szFile, p.count())
+	for i := range files {
+		files[i].hasStream = true
+	}
+	for p.err == nil {
+		typ := p.byte()
+		if typ == szEnd {
+			break
+		}
+		prop := &szReader{b: p.bytes(p.number())}
+		switch typ {
+		case szEmptyStream:
+			for i, empty := range prop.bits(len(files)) {
+				// Empty streams are directories unless marked as empty files.
+				files[i].hasStream = !empty
+				files[i].isDir = empty
+			}
+		case szEmptyFile:
+			numEmpty := 0
+			for _, f := range files {
+				if !f.hasStream {
+					numEmpty++
+				}
+			}
+			emptyFile := prop.bits(numEmpty)
+			j := 0
+			for i := range files {
+				if !files[i].hasStream {
+					files[i].isDir = files[i].isDir && !emptyFile[j]
+					j++
+				}
+			}
+		case szName:
+			if prop.byte() != 0 {
+				p.fail() // names stored in an additional stream
+				break
+			}
+			names := prop.b[prop.off:]
+			for i := range files {
+				var units []uint16
+				for len(names) >= 2 {
+					u := binary.LittleEndian.Uint16(names)
+					names = names[2:]
+					if u == 0 {
+						break
+					}
+					units = append(units, u)
+				}
+				files[i].name = string(utf16.Decode(units))
+			}
+		}
+		if prop.err != nil {
+			p.err = prop.err
+		}
+	}
+	return files
+}
+
+// sevenZip is an opened 7z archive: its streams and file list.
+type sevenZip struct {
+	src     *source
+	streams szStreams
+	files   []szFile
+}
+
+func openSevenZip(src *source) (*sevenZip, error) {
+	sig, ok := readAt(nil, src, 0, sevenZipHeaderSize)
+	if !ok {
+		return nil, errSevenZipCorrupt
+	}
+	nextOff := binary.LittleEndian.Uint64(sig[12:])
+	nextSize := binary.LittleEndian.Uint64(sig[20:])
+	if nextSize > maxSevenZipHeader || nextOff > uint64(src.size) {
+		return nil, errSevenZipCorrupt
+	}
+	hdr, ok := readRegion(nil, src, int64(sevenZipHeaderSize+nextOff), int(nextSize))
+	if !ok || uint64(len(hdr)) != nextSize {
+		return nil, errSevenZipCorrupt
+	}
+
+	a := &sevenZip{src: src}
+	for i := 0; ; i++ {
+		p := &szReader{b: hdr}
+		switch p.byte() {
+		case szHeader:
+			a.streams, a.files = p.header()
+			return a, p.err
+		case szEncodedHeader:
+			if i >= 4 {
+				return nil, errSevenZipCorrupt
+			}
+			// The real header is packed like a member.
+			packed := p.streamsInfo()
+			if p.err != nil || len(packed.folders) == 0 {
+				return nil, errSevenZipCorrupt
+			}
+			enc := &sevenZip{src: src, streams: packed}
+			size := packed.folders[0].unpackSize()
+			if size > maxSevenZipHeader {
+				return nil, errSevenZipCorrupt
+			}
+			r, err := enc.folderReader(0)
+			if err != nil {
+				return nil, err
+			}
+			hdr = make([]byte, size)
+			if _, err := io.ReadFull(r, hdr); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errSevenZipCorrupt
+		}
+	}
+}
+
+// folderReader decodes folder i. Only single-coder folders are supported,
+// which covers the LZMA and LZMA2 7-Zip packs headers with.
+func (a *sevenZip) folderReader(i int) (io.Reader, error) {
+	f := &a.streams.folders[i]
+	packIndex := 0
+	for _, prev := range a.streams.folders[:i] {
+		packIndex += prev.numPacked
+	}
+	if packIndex >= len(a.streams.packSizes) {
+		return nil, errSevenZipCorrupt
+	}
+	off := uint64(sevenZipHeaderSize) + a.streams.packPos
+	for _, size := range a.streams.packSizes[:packIndex] {
+		off += size
+	}
+	size := a.streams.packSizes[packIndex]
+	if off > uint64(a.src.size) || size > uint64(a.src.size)-off {
+		return nil, errSevenZipCorrupt
+	}
+
+	for _, c := range f.coders {
+		if bytes.Equal(c.id, szAES) {
+			return nil, errors.New("encrypted")
+		}
+	}
+	if len(f.coders) != 1 || f.numPacked != 1 {
+		return nil, fmt.Errorf("unsupported 7z coder chain of %d methods", len(f.coders))
+	}
+	packed := io.NewSectionReader(a.src, int64(off), int64(size))
+	return sevenZipDecoder(f.coders[0], packed, f.unpackSize())
+}
+
+// memberReader is folderReader for members, which are only read when
+// stored, as for RAR: reaching one member of a solid block can mean
+// decompressing gigabytes ahead of it.
+func (a *sevenZip) memberReader(i int) (io.Reader, error) {
+	f := &a.streams.folders[i]
+	for _, c := range f.coders {
+		if bytes.Equal(c.id, szAES) {
+			return nil, errors.New("encrypted")
+		}
+	}
+	if len(f.coders) != 1 || string(f.coders[0].id) != "\x00" {
+		return nil, errors.New("7z compressed")
+	}
+	return a.folderReader(i)
+}
+
+func sevenZipDecoder(c szCoder, r io.Reader, size uint64) (io.Reader, error) {
+	switch string(c.id) {
+	case "\x00":
+		return r, nil
+	case "\x03\x01\x01":
+		if len(c.props) != 5 {
+			return nil, errSevenZipCorrupt
+		}
+		// The declared size is only a header field, so it does not vouch
+		// for a large dictionary; it can only shrink one.
+		dict := min(uint64(binary.LittleEndian.Uint32(c.props[1:])), size)
+		if dict > maxSevenZipDict {
+			return nil, fmt.Errorf("7z dictionary of %d bytes too large", dict)
+		}
+		// Give the raw stream the classic .lzma header the decoder expects.
+		hdr := make([]byte, lzma.HeaderLen)
+		hdr[0] = c.props[0]
+		binary.LittleEndian.PutUint32(hdr[1:], uint32(max(dict, lzma.MinDictCap)))
+		binary.LittleEndian.PutUint64(hdr[5:], size)
+		return lzma.NewReader(io.MultiReader(bytes.NewReader(hdr), r))
+	case "\x21":
+		if len(c.props) != 1 || c.props[0] > 40 {
+			return nil, errSevenZipCorrupt
+		}
+		dict := uint64(0xFFFFFFFF)
+		if p := c.props[0]; p < 40 {
+			dict = uint64(2|p&1) << (p/2 + 11)
+		}
+		dict = min(dict, size)
+		if dict > maxSevenZipDict {
+			return nil, fmt.Errorf("7z dictionary of %d bytes too large", dict)
+		}
+		return lzma.Reader2Config{DictCap: max(int(dict), lzma.MinDictCap)}.NewReader2(r)
+	}
+	return nil, fmt.Errorf("unsupported 7z method %x", c.id)
+}
+
+func (w *memberWalker) walk7z(src *source, prefix string, depth int) error {
+	a, err := openSevenZip(src)
+	if err != nil {
+		return err
+	}
+	folders := a.streams.folders
+	folder, sub := 0, 0
+	var fr io.Reader
+	var frErr error
+	for _, f := range a.files {
+		path := prefix + f.name
+		if !f.hasStream {
+			if f.isDir {
+				err = w.special(path, 0, "directory")
+			} else {
+				err = w.visit(path, 0, bytes.NewReader(nil), depth)
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Members are stored back to back in the folders' substreams.
+		for folder < len(folders) && sub >= folders[folder].numSubstreams {
+			folder, sub = folder+1, 0
+		}
+		if folder >= len(folders) {
+			return errSevenZipCorrupt
+		}
+		if sub == 0 {
+			fr, frErr = a.memberReader(folder)
+		}
+		size := folders[folder].unpackSize()
+		if folders[folder].substreams != nil {
+			size = folders[folder].substreams[sub]
+		}
+		sub++
+		if frErr != nil {
+			if err := w.skip(path, int64(size), frErr.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		body := io.LimitReader(fr, int64(size))
+		if err := w.visit(path, int64(size), body, depth); err != nil {
+			return err
+		}
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			frErr = err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package magic
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+)
+
+var errRarCorrupt = errors.New("corrupt RAR header")
+
+// walkRar lists RAR 1.5-4.x and RAR 5 archives. Stored members are
+// classified; anything compressed is reported as skipped, since we have no
+// RAR decoder.
+func (w *memberWalker) walkRar(src *source, prefix string, depth int) error {
+	sig, ok := readAt(nil, src, 0, 8)
+	if !ok {
+		return errRarCorrupt
+	}
+	if hasPrefix(sig, "Rar!\x1A\x07\x01\x00") {
+		return w.walkRar5(src, prefix, depth)
+	}
+	return w.walkRar4(src, prefix, depth)
+}
+
+func (w *memberWalker) walkRar4(src *source, prefix string, depth int) error {
+	for off := int64(7); off < src.size; {
+		base, ok := readRegion(nil, src, off, 7)
+		if !ok || len(base) < 7 {
+			return errRarCorrupt
+		}
+		typ := base[2]
+		flags := peekLe(base[3:], 2)
+		hsize := int64(peekLe(base[5:], 2))
+		if hsize < 7 {
+			return errRarCorrupt
+		}
+		hdr, ok := readRegion(nil, src, off, int(hsize))
+		if !ok || int64(len(hdr)) != hsize {
+			return errRarCorrupt
+		}
+		var add int64
+		if flags&0x8000 != 0 && hsize >= 11 {
+			add = int64(peekLe(hdr[7:], 4))
+		}
+
+		switch typ {
+		case 0x73: // archive header
+			if flags&0x80 != 0 {
+				return errors.New("encrypted RAR headers")
+			}
+		case 0x74: // file header
+			if hsize < 32 {
+				return errRarCorrupt
+			}
+			packSize := int64(peekLe(hdr[7:], 4))
+			size := int64(peekLe(hdr[11:], 4))
+			method := hdr[25]
+			nameSize := peekLe(hdr[26:], 2)
+			nameOff := 32
+			if flags&0x100 != 0 {
+				if hsize < 40 {
+					return errRarCorrupt
+				}
+				packSize |= int64(peekLe(hdr[32:], 4)) << 32
+				size |= int64(peekLe(hdr[36:], 4)) << 32
+				nameOff = 40
+			}
+			if nameOff+nameSize > len(hdr) || packSize < 0 {
+				return errRarCorrupt
+			}
+			name := hdr[nameOff : nameOff+nameSize]
+			if flags&0x200 != 0 {
+				// Unicode names: an ASCII fallback, NUL, then the encoded form.
+				if i := bytes.IndexByte(name, 0); i >= 0 {
+					name = name[:i]
+				}
+			}
+			path := prefix + strings.ReplaceAll(string(name), "\\", "/")
+			add = packSize
+
+			var err error
+			switch {
+			case flags&0xE0 == 0xE0:
+				err = w.special(path, 0, "directory")
+			case flags&0x04 != 0:
+				err = w.skip(path, size, "encrypted")
+			case flags&0x03 != 0:
+				err = w.skip(path, size, "split across volumes")
+			case method == 0x30:
+				err = w.visit(path, size, io.NewSectionReader(src, off+hsize, packSize), depth)
+			default:
+				err = w.skip(path, size, "RAR compressed")
+			}
+			if err != nil {
+				return err
+			}
+		case 0x7B: // end of archive
+			return nil
+		}
+		off += hsize + add
+	}
+	return nil
+}
+
+func (w *memberWalker) walkRar5(src *source, prefix string, depth int) error {
+	for off := int64(8); off < src.size; {
+		// CRC32, then the header size as a vint of at most 3 bytes.
+		base, ok := readRegion(nil, src, off, 7)
+		if !ok || len(base) < 5 {
+			return errRarCorrupt
+		}
+		hsize, n := rarVint(base[4:])
+		if n == 0 || hsize == 0 || hsize > 2<<20 {
+			return errRarCorrupt
+		}
+		start := off + 4 + int64(n)
+		hdr, ok := readRegion(nil, src, start, int(hsize))
+		if !ok || uint64(len(hdr)) != hsize {
+			return errRarCorrupt
+		}
+		p := rarFields{b: hdr}
+		typ := p.vint()
+		flags := p.vint()
+		var extraSize, dataSize uint64
+		if flags&0x01 != 0 {
+			extraSize = p.vint()
+		}
+		if flags&0x02 != 0 {
+			dataSize = p.vint()
+		}
+		dataOff := start + int64(hsize)
+		if p.bad || extraSize > hsize || dataSize > uint64(src.size) {
+			return errRarCorrupt
+		}
+
+		switch typ {
+		case 2: // file header
+			fileFlags := p.vint()
+			size := p.vint()
+			p.vint() // attributes
+			if fileFlags&0x02 != 0 {
+				p.skip(4) // mtime
+			}
+			if fileFlags&0x04 != 0 {
+				p.skip(4) // data CRC32
+			}
+			compression := p.vint()
+			p.vint() // host OS
+			name := p.bytes(p.vint())
+			if p.bad {
+				return errRarCorrupt
+			}
+			path := prefix + string(name)
+			extra := hdr[len(hdr)-int(extraSize):]
+
+			var err error
+			switch {
+			case fileFlags&0x01 != 0:
+				err = w.special(path, 0, "directory")
+			case rar5Encrypted(extra):
+				err = w.skip(path, int64(size), "encrypted")
+			case flags&0x18 != 0:
+				err = w.skip(path, int64(size), "split across volumes")
+			case compression>>7&0x07 == 0:
+				err = w.visit(path, int64(size), io.NewSectionReader(src, dataOff, int64(dataSize)), depth)
+			default:
+				err = w.skip(path, int64(size), "RAR compressed")
+			}
+			if err != nil {
+				return err
+			}
+		case 4: // archive encryption header
+			return errors.New("encrypted RAR headers")
+		case 5: // end of archive
+			return nil
+		}
+		off = dataOff + int64(dataSize)
+	}
+	return nil
+}
+
+// rar5Encrypted reports whether a file header's extra area has a file
+// encryption record.
+func rar5Encrypted(extra []byte) bool {
+	for p := (rarFields{b: extra}); !p.bad && len(p.b) > 0; {
+		size := p.vint()
+		if p.bad || size > uint64(len(p.b)) {
+			return false
+		}
+		record := rarFields{b: p.bytes(size)}
+		if record.vint() == 0x01 {
+			return true
+		}
+	}
+	return false
+}
+
+// rarVint decodes a RAR 5 variable-length integer, returning its length (0
+// when malformed).
+func rarVint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7F) << (7 * i)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// rarFields reads RAR 5 header fields, remembering any overrun.
+type rarFields struct {
+	b   []byte
+	bad bool
+}
+
+func (p *rarFields) vint() uint64 {
+	v, n := rarVint(p.b)
+	if n == 0 {
+		p.bad = true
+		return 0
+	}
+	p.b = p.b[n:]
+	return v
+}
+
+func (p *rarFields) bytes(n uint64) []byte {
+	if n > uint64(len(p.b)) {
+		p.bad = true
+		return nil
+	}
+	v := p.b[:n]
+	p.b = p.b[n:]
+	return v
+}
+
+func (p *rarFields) skip(n uint64) {
+	p.bytes(n)
+}
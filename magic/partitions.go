@@ -0,0 +1,285 @@
+package magic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// maxPartitionDepth bounds how many partition tables deep detection
+	// goes, so a table pointing into itself cannot keep us busy.
+	maxPartitionDepth = 2
+
+	// maxPartitions bounds the entries listed from one table (GPT allows
+	// 128 by default, and logical MBR partitions chain without limit).
+	maxPartitions = 128
+
+	sectorSize = 512
+)
+
+// partition is one entry of an MBR or GPT partition table.
+type partition struct {
+	index    int
+	mbrType  int    // MBR system ID, or -1 for GPT
+	typeGUID string // GPT partition type
+	name     string // GPT partition name
+	active   bool
+	start    int64 // first sector
+	sectors  int64
+}
+
+// partitionReaders list the partitions of disk images, keyed by matcher
+// name.
+var partitionReaders = map[string]func([]byte, *source) []partition{
+	"gpt":                 gptPartitions,
+	"dos-mbr-boot-sector": mbrPartitions,
+}
+
+// mbrTypes names common MBR system IDs.
+var mbrTypes = map[int]string{
+	0x01: "FAT12",
+	0x04: "FAT16 <32M",
+	0x05: "Extended",
+	0x06: "FAT16",
+	0x07: "NTFS/exFAT/HPFS",
+	0x0B: "FAT32",
+	0x0C: "FAT32 (LBA)",
+	0x0E: "FAT16 (LBA)",
+	0x0F: "Extended (LBA)",
+	0x27: "Windows recovery",
+	0x82: "Linux swap",
+	0x83: "Linux",
+	0x85: "Linux extended",
+	0x8E: "Linux LVM",
+	0xA5: "FreeBSD",
+	0xA6: "OpenBSD",
+	0xA9: "NetBSD",
+	0xAF: "Apple HFS/HFS+",
+	0xEE: "GPT protective",
+	0xEF: "EFI System",
+	0xFD: "Linux RAID",
+}
+
+func isExtendedPartition(id int) bool {
+	return id == 0x05 || id == 0x0F || id == 0x85
+}
+
+// mbrPartitions lists the primary partitions of a master boot record and
+// the logical partitions chained from its extended partition, numbered
+// from 5 as Linux does. Volume boot sectors have no table.
+func mbrPartitions(b []byte, file *source) []partition {
+	if len(b) < 512 || looksLikeFatBootSector(b) || !hasLikelyMbrPartitionTable(b) {
+		return nil
+	}
+	var parts []partition
+	extended := int64(-1)
+	for i := 0; i < 4; i++ {
+		e := b[446+16*i:]
+		p := partition{
+			index:   i + 1,
+			mbrType: int(e[4]),
+			active:  e[0] == 0x80,
+			start:   int64(binary.LittleEndian.Uint32(e[8:])),
+			sectors: int64(binary.LittleEndian.Uint32(e[12:])),
+		}
+		if p.mbrType == 0 || p.sectors == 0 {
+			continue
+		}
+		if isExtendedPartition(p.mbrType) && extended < 0 {
+			extended = p.start
+		}
+		parts = append(parts, p)
+	}
+
+	// Each extended boot record holds a logical partition, relative to
+	// itself, and a link to the next record, relative to the extended
+	// partition.
+	ebr := extended
+	for index := 5; ebr > 0 && index < 5+maxPartitions; index++ {
+		sector, ok := readRegion(b, file, ebr*sectorSize, 512)
+		if !ok || sector[510] != 0x55 || sector[511] != 0xAA {
+			break
+		}
+		e := sector[446:]
+		if p := (partition{
+			index:   index,
+			mbrType: int(e[4]),
+			active:  e[0] == 0x80,
+			start:   ebr + int64(binary.LittleEndian.Uint32(e[8:])),
+			sectors: int64(binary.LittleEndian.Uint32(e[12:])),
+		}); p.mbrType != 0 && p.sectors != 0 {
+			parts = append(parts, p)
+		}
+		next := sector[462:]
+		if !isExtendedPartition(int(next[4])) {
+			break
+		}
+		link := extended + int64(binary.LittleEndian.Uint32(next[8:]))
+		if link <= ebr {
+			break
+		}
+		ebr = link
+	}
+	return parts
+}
+
+// gptTypes names common GPT partition type GUIDs.
+var gptTypes = map[string]string{
+	"C12A7328-F81F-11D2-BA4B-00A0C93EC93B": "EFI System",
+	"21686148-6449-6E6F-744E-656564454649": "BIOS boot",
+	"E3C9E316-0B5C-4DB8-817D-F92DF00215AE": "Microsoft reserved",
+	"EBD0A0A2-B9E5-4433-87C0-68B6B72699C7": "Microsoft basic data",
+	"DE94BBA4-06D1-4D40-A16A-BFD50179D6AC": "Windows recovery",
+	"0FC63DAF-8483-4772-8E79-3D69D8477DE4": "Linux filesystem",
+	"0657FD6D-A4AB-43C4-84E5-0933C84B4F4F": "Linux swap",
+	"E6D6D379-F507-44C2-A23C-238F2A3DF928": "Linux LVM",
+	"A19D880F-05FC-4D3B-A006-743F0F84911E": "Linux RAID",
+	"4F68BCE3-E8CD-4DB1-96E7-FBCAF984B709": "Linux root (x86-64)",
+	"933AC7E1-2EB4-4F13-B844-0E14E2AEF915": "Linux home",
+	"BC13C2FF-59E6-4262-A352-B275FD6F7172": "Linux extended boot",
+	"CA7D7CCB-63ED-4C53-861C-1742536059CC": "LUKS",
+	"48465300-0000-11AA-AA11-00306543ECAC": "Apple HFS+",
+	"7C3457EF-0000-11AA-AA11-00306543ECAC": "Apple APFS",
+	"426F6F74-0000-11AA-AA11-00306543ECAC": "Apple boot",
+	"516E7CB4-6ECF-11D6-8FF8-00022D09712B": "FreeBSD data",
+	"516E7CB6-6ECF-11D6-8FF8-00022D09712B": "FreeBSD UFS",
+	"516E7CBA-6ECF-11D6-8FF8-00022D09712B": "FreeBSD ZFS",
+}
+
+// guidString formats a GUID stored with its first three fields
+// little-endian, as GPT and most Microsoft formats do.
+func guidString(g []byte) string {
+	return fmt.Sprintf("%08X-%04X-%04X-%X-%X",
+		binary.LittleEndian.Uint32(g), binary.LittleEndian.Uint16(g[4:]), binary.LittleEndian.Uint16(g[6:]), g[8:10], g[10:16])
+}
+
+// gptPartitions lists the used entries of the partition array the GPT
+// header at LBA 1 points to.
+func gptPartitions(b []byte, file *source) []partition {
+	hdr, ok := readRegion(b, file, sectorSize, 92)
+	if !ok || !hasPrefix(hdr, "EFI PART") {
+		return nil
+	}
+	array := int64(binary.LittleEndian.Uint64(hdr[72:]))
+	count := int(binary.LittleEndian.Uint32(hdr[80:]))
+	size := int(binary.LittleEndian.Uint32(hdr[84:]))
+	if array < 2 || array > 1<<40 || size < 128 || size > 4096 || size%8 != 0 || count <= 0 {
+		return nil
+	}
+	count = min(count, maxPartitions)
+	entries, ok := readRegion(b, file, array*sectorSize, count*size)
+	if !ok {
+		return nil
+	}
+
+	var parts []partition
+	zero := make([]byte, 16)
+	// LBAs are 64-bit; entries beyond the image would overflow once
+	// converted to byte offsets.
+	sectors := inputSize(b, file) / sectorSize
+	for i := 0; i < count; i++ {
+		e := entries[i*size:]
+		if bytes.Equal(e[:16], zero) {
+			continue
+		}
+		first := int64(binary.LittleEndian.Uint64(e[32:]))
+		last := int64(binary.LittleEndian.Uint64(e[40:]))
+		if first <= 0 || last < first || first > sectors || last-first+1 > sectors {
+			continue
+		}
+		parts = append(parts, partition{
+			index:    i + 1,
+			mbrType:  -1,
+			typeGUID: guidString(e[:16]),
+			name:     utf16z(e[56:128]),
+			start:    first,
+			sectors:  last - first + 1,
+		})
+	}
+	return parts
+}
+
+// partitionedResult lists the partitions of a disk image and classifies
+// the content of each, like file -s on a whole disk:
+// "DOS/MBR boot sector; partition 1: ID=0x83, active, start LBA 2048,
+// 204800 sectors, Linux ext4 filesystem".
+func partitionedResult(res Result, opts Options, contentByte []byte, file *source) Result {
+	read, ok := partitionReaders[res.Matcher]
+	if !ok || file == nil || opts.depth >= maxPartitionDepth {
+		return res
+	}
+	parts := read(contentByte, file)
+	if len(parts) == 0 {
+		return res
+	}
+
+	inner := opts
+	inner.depth++
+	inner.KeepGoing = false
+	inner.Filename = ""
+	var desc strings.Builder
+	desc.WriteString(res.Description)
+	list := make([]map[string]any, 0, len(parts))
+	for _, p := range parts {
+		f := map[string]any{"index": p.index, "start_lba": p.start, "sectors": p.sectors, "size": p.sectors * sectorSize}
+		fmt.Fprintf(&desc, "; partition %d: ", p.index)
+		if p.mbrType >= 0 {
+			fmt.Fprintf(&desc, "ID=0x%02x", p.mbrType)
+			f["type"] = fmt.Sprintf("0x%02x", p.mbrType)
+			if name := mbrTypes[p.mbrType]; name != "" {
+				f["type_name"] = name
+			}
+		} else {
+			desc.WriteString("type=" + p.typeGUID)
+			f["type"] = p.typeGUID
+			if name := gptTypes[p.typeGUID]; name != "" {
+				desc.WriteString(" (" + name + ")")
+				f["type_name"] = name
+			}
+		}
+		if p.name != "" && isPrintableASCII(p.name) {
+			fmt.Fprintf(&desc, ", name %q", p.name)
+			f["name"] = p.name
+		}
+		if p.active {
+			desc.WriteString(", active")
+			f["active"] = true
+		}
+		fmt.Fprintf(&desc, ", start LBA %d, %d sectors", p.start, p.sectors)
+
+		// Extended partitions are containers whose logical partitions are
+		// listed themselves.
+		off := p.start * sectorSize
+		if p.mbrType >= 0 && isExtendedPartition(p.mbrType) || off <= 0 || off >= file.size {
+			list = append(list, f)
+			continue
+		}
+		length := min(p.sectors*sectorSize, file.size-off)
+		if length <= 0 {
+			list = append(list, f)
+			continue
+		}
+		got, err := Detect(io.NewSectionReader(file, off, length), length, inner)
+		if err == nil && got.Matcher != "" && got.Matcher != "data" {
+			desc.WriteString(", " + got.Description)
+			f["filesystem"] = got.Description
+			if got.Fields != nil {
+				f["filesystem_fields"] = got.Fields
+			}
+		}
+		list = append(list, f)
+	}
+
+	res.Description = desc.String()
+	if res.Fields == nil {
+		res.Fields = map[string]any{}
+	}
+	res.Fields["partitions"] = list
+	if len(res.Candidates) > 0 {
+		res.Candidates[0].Description = res.Description
+	}
+	return res
+}
@@ -0,0 +1,455 @@
+package magic
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxPDFScan bounds how much of a PDF is scanned for objects. Larger
+	// files are read from the start and the last pdfTrailerScan bytes,
+	// where the trailer and (for linearized files) the first page's
+	// objects are, and reported as partially scanned.
+	maxPDFScan = 32 << 20
+
+	// pdfTrailerScan is how much of the end of a large PDF is scanned.
+	pdfTrailerScan = 1 << 20
+
+	// maxPDFInflate bounds the object and metadata streams decompressed for
+	// one file.
+	maxPDFInflate = 64 << 20
+)
+
+// pdfInfo is what describePDF reports beyond the header version.
+type pdfInfo struct {
+	version    string
+	pages      int
+	encrypted  bool
+	linearized bool
+	pdfa       string // "1b", "2u", ...
+	truncated  bool   // the middle of a large file was not scanned
+
+	javaScript    bool
+	openAction    bool
+	embeddedFiles bool
+	launch        bool
+
+	rootPages int // /Count of a /Pages node without /Parent, -1 if none
+	maxPages  int
+	inflated  int
+}
+
+// describePDF reports the version, page count, encryption, linearization
+// and PDF/A conformance of a PDF, and flags the actions document intake
+// usually screens for. Names are matched after #xx unescaping, and object
+// streams are inflated so compressed dictionaries are seen too. The page
+// count comes from the page tree the trailer's /Root points to, found
+// through the cross-reference data, when that can be followed.
+func describePDF(b []byte, file *source) (string, map[string]any) {
+	info := pdfInfo{rootPages: -1}
+	if len(b) >= 8 && hasPrefix(b, "%PDF-") {
+		ver := strings.TrimRight(string(b[5:8]), "\r\n \x00")
+		if len(ver) == 3 && ver[1] == '.' {
+			info.version = ver
+		}
+	}
+
+	data := b
+	if file != nil {
+		if file.size <= maxPDFScan {
+			data, _ = readAt(b, file, 0, int(file.size))
+		} else {
+			head, _ := readAt(b, file, 0, maxPDFScan-pdfTrailerScan)
+			tail, _ := readTail(file, pdfTrailerScan)
+			data = append(head, tail...)
+			info.truncated = true
+		}
+	}
+	info.scan(data, true)
+
+	info.pages = info.maxPages
+	if info.rootPages >= 0 {
+		info.pages = info.rootPages
+	}
+	if x := readPDFXref(&info, file); x != nil {
+		if n, ok := x.pages(); ok {
+			info.pages = n
+		}
+	}
+	return info.describe(), info.fields()
+}
+
+var pdfObjHeader = regexp.MustCompile(`(?:^|[^0-9])[0-9]+[ \t\r\n\f\x00]+[0-9]+[ \t\r\n\f\x00]+obj$`)
+
+// pdfObjHeaders finds every "N G obj" in data, like FindAllIndex. It looks
+// for the keyword first and matches the numbers in the bytes before it, as
+// running the expression over every byte of a large file is slow.
+func pdfObjHeaders(data []byte) [][]int {
+	var locs [][]int
+	for pos := 0; ; {
+		i := bytes.Index(data[pos:], []byte("obj"))
+		if i < 0 {
+			return locs
+		}
+		end := pos + i + len("obj")
+		pos = end
+		if end < len(data) && isWordByte(data[end]) {
+			continue
+		}
+		lo := max(0, end-64)
+		loc := pdfObjHeader.FindIndex(data[lo:end])
+		// A match at the start of the window may have cut a number short.
+		if loc == nil || lo > 0 && loc[0] == 0 && isDigitByte(data[lo]) && isDigitByte(data[lo-1]) {
+			continue
+		}
+		locs = append(locs, []int{lo + loc[0], end})
+	}
+}
+
+func isWordByte(c byte) bool {
+	return isDigitByte(c) || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+// scan analyses every "N G obj ... endobj" in data. top is false inside
+// object streams, whose objects have no obj/endobj brackets.
+func (info *pdfInfo) scan(data []byte, top bool) {
+	first := true
+	for _, loc := range pdfObjHeaders(data) {
+		body := data[loc[1]:]
+		if end := bytes.Index(body, []byte("endobj")); end >= 0 {
+			body = body[:end]
+		}
+		dict, stream := body, []byte(nil)
+		if i := bytes.Index(body, []byte("stream")); i >= 0 && !bytes.HasSuffix(body[:i], []byte("end")) {
+			dict = body[:i]
+			stream = body[i+len("stream"):]
+			stream = bytes.TrimPrefix(stream, []byte("\r"))
+			stream = bytes.TrimPrefix(stream, []byte("\n"))
+			if end := bytes.LastIndex(stream, []byte("endstream")); end >= 0 {
+				stream = stream[:end]
+			}
+		}
+		d := info.object(dict)
+		if top && first && d.keys["/Linearized"] {
+			info.linearized = true
+		}
+		first = false
+		if stream != nil {
+			info.stream(d, stream)
+		}
+	}
+	// The trailer dictionary of classic cross-reference tables.
+	for rest := data; top; {
+		i := bytes.Index(rest, []byte("trailer"))
+		if i < 0 {
+			break
+		}
+		rest = rest[i+len("trailer"):]
+		info.object(rest[:min(len(rest), 4096)])
+	}
+}
+
+// pdfDict is the top level of a dictionary: its keys and the values of the
+// ones describePDF needs.
+type pdfDict struct {
+	keys   map[string]bool
+	values map[string]string // first token after the key
+	refs   map[string]int    // object number of "N G R" values
+}
+
+// object records what a dictionary tells us and flags interesting names at
+// any nesting depth.
+func (info *pdfInfo) object(text []byte) pdfDict {
+	d := pdfDict{keys: map[string]bool{}, values: map[string]string{}, refs: map[string]int{}}
+	tokens := pdfTokens(text)
+	depth, closed := 0, false
+	for i, tok := range tokens {
+		switch tok {
+		case "<<":
+			depth++
+			continue
+		case ">>":
+			// Only the first dictionary's keys belong to this object, but
+			// actions are flagged wherever they appear.
+			if depth--; depth <= 0 {
+				closed = true
+			}
+			continue
+		case "/JavaScript", "/JS":
+			info.javaScript = true
+		case "/OpenAction":
+			info.openAction = true
+		case "/EmbeddedFile", "/EmbeddedFiles":
+			info.embeddedFiles = true
+		case "/Launch":
+			info.launch = true
+		case "/Encrypt":
+			if depth == 1 && !closed {
+				info.encrypted = true
+			}
+		}
+		if depth == 1 && !closed && strings.HasPrefix(tok, "/") && i+1 < len(tokens) && !d.keys[tok] {
+			d.keys[tok] = true
+			d.values[tok] = tokens[i+1]
+			if i+3 < len(tokens) && tokens[i+3] == "R" {
+				n, err1 := strconv.Atoi(tokens[i+1])
+				_, err2 := strconv.Atoi(tokens[i+2])
+				if err1 == nil && err2 == nil && n > 0 {
+					d.refs[tok] = n
+				}
+			}
+		}
+	}
+
+	if d.values["/Type"] == "/Pages" {
+		if n, err := strconv.Atoi(d.values["/Count"]); err == nil && n >= 0 {
+			info.maxPages = max(info.maxPages, n)
+			if !d.keys["/Parent"] {
+				info.rootPages = max(info.rootPages, n)
+			}
+		}
+	}
+	return d
+}
+
+// stream inflates object streams and XMP metadata.
+func (info *pdfInfo) stream(d pdfDict, data []byte) {
+	typ := d.values["/Type"]
+	if typ != "/ObjStm" && typ != "/Metadata" {
+		return
+	}
+	data, ok := info.decode(d, data)
+	if !ok {
+		return
+	}
+
+	if typ == "/Metadata" {
+		if part := pdfaConformance(data); part != "" {
+			info.pdfa = part
+		}
+		return
+	}
+	for _, obj := range pdfObjStm(d, data) {
+		info.object(obj)
+	}
+}
+
+// decode undoes the /FlateDecode filter of a stream, within the inflate
+// budget; other filters are not supported.
+func (info *pdfInfo) decode(d pdfDict, data []byte) ([]byte, bool) {
+	filter := d.values["/Filter"]
+	if filter == "[" {
+		filter = "/FlateDecode" // a one-element filter array is the usual case
+	}
+	switch filter {
+	case "":
+		return data, true
+	case "/FlateDecode":
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, false
+		}
+		data, _ = io.ReadAll(io.LimitReader(zr, int64(maxPDFInflate-info.inflated)))
+		info.inflated += len(data)
+		return data, true
+	}
+	return nil, false
+}
+
+// pdfObjStm splits a decoded object stream into its objects, in index
+// order. It starts with N pairs of object number and offset, relative to
+// /First.
+func pdfObjStm(d pdfDict, data []byte) [][]byte {
+	n, _ := strconv.Atoi(d.values["/N"])
+	first, _ := strconv.Atoi(d.values["/First"])
+	if n <= 0 || first <= 0 || first > len(data) {
+		return nil
+	}
+	fields := strings.Fields(string(data[:first]))
+	var offsets []int
+	for i := 1; i < len(fields) && len(offsets) < n; i += 2 {
+		off, err := strconv.Atoi(fields[i])
+		if err != nil || off < 0 || first+off > len(data) {
+			return nil
+		}
+		offsets = append(offsets, first+off)
+	}
+	objects := make([][]byte, len(offsets))
+	for i, off := range offsets {
+		end := len(data)
+		if i+1 < len(offsets) && offsets[i+1] >= off {
+			end = offsets[i+1]
+		}
+		objects[i] = data[off:end]
+	}
+	return objects
+}
+
+var pdfaPart = regexp.MustCompile(`pdfaid:part(?:="|>)\s*([1-4])`)
+var pdfaConf = regexp.MustCompile(`pdfaid:conformance(?:="|>)\s*([ABUabu])`)
+
+// pdfaConformance reads the PDF/A identification from XMP metadata.
+func pdfaConformance(xmp []byte) string {
+	m := pdfaPart.FindSubmatch(xmp)
+	if m == nil {
+		return ""
+	}
+	part := string(m[1])
+	if c := pdfaConf.FindSubmatch(xmp); c != nil {
+		part += strings.ToLower(string(c[1]))
+	}
+	return part
+}
+
+// pdfTokens splits PDF syntax into names (with #xx escapes decoded),
+// numbers, keywords and the delimiters "<<", ">>", "[" and "]". Strings
+// and comments are dropped.
+func pdfTokens(text []byte) []string {
+	var out []string
+	for i := 0; i < len(text) && len(out) < 1<<16; {
+		c := text[i]
+		switch {
+		case pdfWhitespace(c):
+			i++
+		case c == '%':
+			for i < len(text) && text[i] != '\n' && text[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			// Literal strings nest and escape parentheses.
+			depth := 0
+			for ; i < len(text); i++ {
+				if text[i] == '\\' {
+					i++
+				} else if text[i] == '(' {
+					depth++
+				} else if text[i] == ')' {
+					if depth--; depth == 0 {
+						i++
+						break
+					}
+				}
+			}
+		case c == '<' && i+1 < len(text) && text[i+1] == '<', c == '>' && i+1 < len(text) && text[i+1] == '>':
+			out = append(out, string(text[i:i+2]))
+			i += 2
+		case c == '<':
+			if end := bytes.IndexByte(text[i:], '>'); end >= 0 {
+				i += end + 1
+			} else {
+				i = len(text)
+			}
+		case c == '[' || c == ']' || c == '{' || c == '}' || c == '>' || c == ')':
+			out = append(out, string(c))
+			i++
+		case c == '/':
+			var name strings.Builder
+			name.WriteByte('/')
+			for i++; i < len(text) && !pdfWhitespace(text[i]) && !pdfDelimiter(text[i]); i++ {
+				if text[i] == '#' && i+2 < len(text) && isHexDigit(text[i+1]) && isHexDigit(text[i+2]) {
+					name.WriteByte(byte(hexDigit(text[i+1])<<4 | hexDigit(text[i+2])))
+					i += 2
+					continue
+				}
+				name.WriteByte(text[i])
+			}
+			out = append(out, name.String())
+		default:
+			start := i
+			for i < len(text) && !pdfWhitespace(text[i]) && !pdfDelimiter(text[i]) {
+				i++
+			}
+			out = append(out, string(text[start:i]))
+		}
+	}
+	return out
+}
+
+func pdfWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func pdfDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (info pdfInfo) describe() string {
+	var out strings.Builder
+	out.WriteString("PDF document")
+	if info.version != "" {
+		out.WriteString(", version " + info.version)
+	}
+	if info.pages == 1 {
+		out.WriteString(", 1 page")
+	} else if info.pages > 1 {
+		fmt.Fprintf(&out, ", %d pages", info.pages)
+	}
+	if info.pdfa != "" {
+		out.WriteString(", PDF/A-" + info.pdfa)
+	}
+	if info.linearized {
+		out.WriteString(", linearized")
+	}
+	if info.encrypted {
+		out.WriteString(", encrypted")
+	}
+	var actions []string
+	for _, a := range []struct {
+		set  bool
+		name string
+	}{
+		{info.javaScript, "/JavaScript"},
+		{info.openAction, "/OpenAction"},
+		{info.embeddedFiles, "/EmbeddedFile"},
+		{info.launch, "/Launch"},
+	} {
+		if a.set {
+			actions = append(actions, a.name)
+		}
+	}
+	if len(actions) > 0 {
+		out.WriteString(", with " + strings.Join(actions, " "))
+	}
+	if info.truncated {
+		out.WriteString(", partially scanned")
+	}
+	return out.String()
+}
+
+func (info pdfInfo) fields() map[string]any {
+	f := map[string]any{}
+	if info.version != "" {
+		f["version"] = info.version
+	}
+	if info.pages > 0 {
+		f["pages"] = info.pages
+	}
+	for _, flag := range []struct {
+		set bool
+		key string
+	}{
+		{info.encrypted, "encrypted"},
+		{info.linearized, "linearized"},
+		{info.javaScript, "javascript"},
+		{info.openAction, "open_action"},
+		{info.embeddedFiles, "embedded_files"},
+		{info.launch, "launch"},
+		{info.truncated, "truncated"},
+	} {
+		if flag.set {
+			f[flag.key] = true
+		}
+	}
+	if info.pdfa != "" {
+		f["pdfa"] = info.pdfa
+	}
+	if len(f) == 0 {
+		return nil
+	}
+	return f
+}
@@ -0,0 +1,458 @@
+package magic
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxPDFXrefSections bounds the /Prev chain of incremental updates
+	// followed from the last cross-reference section.
+	maxPDFXrefSections = 64
+
+	// maxPDFXrefSubsections bounds the subsections of one classic table.
+	maxPDFXrefSubsections = 1024
+
+	// maxPDFStream bounds the stream read for one cross-reference or
+	// object stream.
+	maxPDFStream = 16 << 20
+)
+
+// pdfXref is the cross-reference data of a PDF, newest section first, as
+// reached from the startxref at the end of the file. Entries are looked up
+// when needed rather than loaded, since only the catalog and the root of the
+// page tree are.
+type pdfXref struct {
+	info     *pdfInfo
+	file     *source
+	sections []pdfXrefSection
+	root     int // object number of the document catalog
+}
+
+// pdfXrefSection is a classic table, whose 20-byte entries are read from
+// the file, or a decoded cross-reference stream of rows of w[0]+w[1]+w[2]
+// bytes.
+type pdfXrefSection struct {
+	subs []pdfXrefSubsection
+	rows []byte
+	w    [3]int
+}
+
+// pdfXrefSubsection covers objects first to first+count-1. pos is the file
+// offset of the first entry of a table, or the first row of a stream.
+type pdfXrefSubsection struct {
+	first, count int
+	pos          int64
+}
+
+// pdfXrefEntry locates an object: at off in the file, or as the index-th
+// object of object stream stm when stm > 0.
+type pdfXrefEntry struct {
+	off   int64
+	stm   int
+	index int
+}
+
+// readPDFXref follows startxref and the /Prev links of the trailers. Their
+// dictionaries go through info.object, so /Encrypt is noted as elsewhere.
+// It returns nil if not even the last section can be read.
+func readPDFXref(info *pdfInfo, file *source) *pdfXref {
+	if file == nil {
+		return nil
+	}
+	tail, ok := readTail(file, int(min(file.size, 1024)))
+	if !ok {
+		return nil
+	}
+	i := bytes.LastIndex(tail, []byte("startxref"))
+	if i < 0 {
+		return nil
+	}
+	fields := strings.Fields(string(tail[i+len("startxref"):]))
+	if len(fields) == 0 {
+		return nil
+	}
+	off, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	x := &pdfXref{info: info, file: file}
+	seen := map[int64]bool{}
+	for len(x.sections) < maxPDFXrefSections && off > 0 && off < file.size && !seen[off] {
+		seen[off] = true
+		sec, trailer, ok := x.section(off)
+		if !ok {
+			break
+		}
+		x.sections = append(x.sections, sec)
+		if x.root == 0 {
+			x.root = trailer.refs["/Root"]
+		}
+		if off, err = strconv.ParseInt(trailer.values["/Prev"], 10, 64); err != nil {
+			break
+		}
+	}
+	if len(x.sections) == 0 {
+		return nil
+	}
+	return x
+}
+
+// section reads the table or stream at off and its trailer dictionary.
+func (x *pdfXref) section(off int64) (pdfXrefSection, pdfDict, bool) {
+	head, ok := readRegion(nil, x.file, off, int(min(x.file.size-off, 64)))
+	if !ok {
+		return pdfXrefSection{}, pdfDict{}, false
+	}
+	if bytes.HasPrefix(bytes.TrimLeft(head, " \t\r\n\f\x00"), []byte("xref")) {
+		return x.table(off + int64(bytes.Index(head, []byte("xref"))+len("xref")))
+	}
+	return x.stream(off)
+}
+
+// table reads the subsection headers of a classic table starting at pos,
+// skipping over their entries, up to the trailer.
+func (x *pdfXref) table(pos int64) (pdfXrefSection, pdfDict, bool) {
+	var sec pdfXrefSection
+	for len(sec.subs) < maxPDFXrefSubsections {
+		line, ok := readRegion(nil, x.file, pos, int(min(x.file.size-pos, 64)))
+		if !ok {
+			break
+		}
+		n := 0
+		for n < len(line) && pdfWhitespace(line[n]) {
+			n++
+		}
+		pos, line = pos+int64(n), line[n:]
+		if bytes.HasPrefix(line, []byte("trailer")) {
+			pos += int64(len("trailer"))
+			dict, ok := readRegion(nil, x.file, pos, int(min(x.file.size-pos, 4096)))
+			if !ok {
+				break
+			}
+			return sec, x.info.object(dict), true
+		}
+
+		// "first count", then count entries of exactly 20 bytes.
+		eol := bytes.IndexAny(line, "\r\n")
+		if eol < 0 {
+			break
+		}
+		f := strings.Fields(string(line[:eol]))
+		if len(f) != 2 {
+			break
+		}
+		first, err1 := strconv.Atoi(f[0])
+		count, err2 := strconv.Atoi(f[1])
+		if err1 != nil || err2 != nil || first < 0 || count < 0 {
+			break
+		}
+		for eol < len(line) && pdfWhitespace(line[eol]) {
+			eol++
+		}
+		pos += int64(eol)
+		if int64(count) > (x.file.size-pos)/20 {
+			break
+		}
+		sec.subs = append(sec.subs, pdfXrefSubsection{first: first, count: count, pos: pos})
+		pos += 20 * int64(count)
+	}
+	return pdfXrefSection{}, pdfDict{}, false
+}
+
+// stream decodes the cross-reference stream at off, whose dictionary is
+// also the trailer.
+func (x *pdfXref) stream(off int64) (pdfXrefSection, pdfDict, bool) {
+	d, tokens, data, ok := x.objectAt(off, -1, true)
+	if !ok || d.values["/Type"] != "/XRef" {
+		return pdfXrefSection{}, pdfDict{}, false
+	}
+	if data, ok = x.info.decode(d, data); !ok {
+		return pdfXrefSection{}, pdfDict{}, false
+	}
+	if p := pdfInts(tokens, "/Predictor"); len(p) == 1 && p[0] >= 10 {
+		columns := 1
+		if c := pdfInts(tokens, "/Columns"); len(c) == 1 {
+			columns = c[0]
+		}
+		if data, ok = pdfUnpredict(data, columns); !ok {
+			return pdfXrefSection{}, pdfDict{}, false
+		}
+	}
+
+	var sec pdfXrefSection
+	w := pdfInts(tokens, "/W")
+	if len(w) != 3 {
+		return pdfXrefSection{}, pdfDict{}, false
+	}
+	for i, n := range w {
+		if n < 0 || n > 8 {
+			return pdfXrefSection{}, pdfDict{}, false
+		}
+		sec.w[i] = n
+	}
+	width := w[0] + w[1] + w[2]
+	if width == 0 {
+		return pdfXrefSection{}, pdfDict{}, false
+	}
+	sec.rows = data
+
+	index := pdfInts(tokens, "/Index")
+	if index == nil {
+		size, err := strconv.Atoi(d.values["/Size"])
+		if err != nil {
+			return pdfXrefSection{}, pdfDict{}, false
+		}
+		index = []int{0, size}
+	}
+	rows := len(data) / width
+	row := 0
+	for i := 0; i+1 < len(index) && row < rows; i += 2 {
+		first, count := index[i], min(index[i+1], rows-row)
+		if first < 0 || count < 0 {
+			break
+		}
+		sec.subs = append(sec.subs, pdfXrefSubsection{first: first, count: count, pos: int64(row)})
+		row += count
+	}
+	return sec, d, true
+}
+
+// lookup finds object n in the newest section that lists it. Free entries
+// hide older ones, as they do for readers.
+func (x *pdfXref) lookup(n int) (pdfXrefEntry, bool) {
+	for _, sec := range x.sections {
+		for _, sub := range sec.subs {
+			if n < sub.first || n-sub.first >= sub.count {
+				continue
+			}
+			i := int64(n - sub.first)
+			if sec.rows == nil {
+				e, ok := readRegion(nil, x.file, sub.pos+20*i, 18)
+				if !ok {
+					return pdfXrefEntry{}, false
+				}
+				f := strings.Fields(string(e))
+				if len(f) != 3 || f[2] != "n" {
+					return pdfXrefEntry{}, false
+				}
+				off, err := strconv.ParseInt(f[0], 10, 64)
+				return pdfXrefEntry{off: off}, err == nil
+			}
+
+			width := int64(sec.w[0] + sec.w[1] + sec.w[2])
+			row := sec.rows[(sub.pos+i)*width:]
+			field := func(k int) int64 {
+				v := int64(peekBe(row, sec.w[k]))
+				row = row[sec.w[k]:]
+				return v
+			}
+			typ := int64(1) // the default when the type field is absent
+			if sec.w[0] > 0 {
+				typ = field(0)
+			}
+			a, b := field(1), field(2)
+			switch typ {
+			case 1:
+				return pdfXrefEntry{off: a}, true
+			case 2:
+				return pdfXrefEntry{stm: int(a), index: int(b)}, a > 0
+			}
+			return pdfXrefEntry{}, false
+		}
+	}
+	return pdfXrefEntry{}, false
+}
+
+// object returns the dictionary of object n, unpacking it from its object
+// stream if need be.
+func (x *pdfXref) object(n int) (pdfDict, bool) {
+	e, ok := x.lookup(n)
+	if !ok {
+		return pdfDict{}, false
+	}
+	if e.stm == 0 {
+		d, _, _, ok := x.objectAt(e.off, n, false)
+		return d, ok
+	}
+	se, ok := x.lookup(e.stm)
+	if !ok || se.stm != 0 {
+		return pdfDict{}, false
+	}
+	d, _, data, ok := x.objectAt(se.off, e.stm, true)
+	if !ok || d.values["/Type"] != "/ObjStm" {
+		return pdfDict{}, false
+	}
+	if data, ok = x.info.decode(d, data); !ok {
+		return pdfDict{}, false
+	}
+	objects := pdfObjStm(d, data)
+	if e.index < 0 || e.index >= len(objects) {
+		return pdfDict{}, false
+	}
+	return x.info.object(objects[e.index]), true
+}
+
+// objectAt reads "n G obj" at off (any number when n is negative): its
+// dictionary, the tokens of that dictionary, and with withStream the
+// stream that follows it, which needs a /Length.
+func (x *pdfXref) objectAt(off int64, n int, withStream bool) (pdfDict, []string, []byte, bool) {
+	if off <= 0 || off >= x.file.size {
+		return pdfDict{}, nil, nil, false
+	}
+	head, ok := readRegion(nil, x.file, off, int(min(x.file.size-off, 4096)))
+	if !ok {
+		return pdfDict{}, nil, nil, false
+	}
+	start := len(head) - len(bytes.TrimLeft(head, " \t\r\n\f\x00"))
+	locs := pdfObjHeaders(head[start:min(len(head), start+64)])
+	if len(locs) == 0 || locs[0][0] != 0 {
+		return pdfDict{}, nil, nil, false
+	}
+	loc := locs[0]
+	if num, err := strconv.Atoi(strings.Fields(string(head[start : start+loc[1]]))[0]); err != nil || n >= 0 && num != n {
+		return pdfDict{}, nil, nil, false
+	}
+	body := head[start+loc[1]:]
+	dict := body
+	if end := bytes.Index(body, []byte("endobj")); end >= 0 {
+		dict = body[:end]
+	}
+	si := bytes.Index(dict, []byte("stream"))
+	if si >= 0 {
+		dict = dict[:si]
+	}
+	d := x.info.object(dict)
+	tokens := pdfTokens(dict)
+	if !withStream {
+		return d, tokens, nil, true
+	}
+	if si < 0 {
+		return pdfDict{}, nil, nil, false
+	}
+
+	// A cross-reference stream's /Length is direct; an object stream's may
+	// be another object holding just the number.
+	length, err := strconv.Atoi(d.values["/Length"])
+	if ref := d.refs["/Length"]; ref > 0 && ref != n {
+		e, ok := x.lookup(ref)
+		if !ok || e.stm != 0 {
+			return pdfDict{}, nil, nil, false
+		}
+		_, t, _, ok := x.objectAt(e.off, ref, false)
+		if !ok || len(t) == 0 {
+			return pdfDict{}, nil, nil, false
+		}
+		length, err = strconv.Atoi(t[0])
+	}
+	if err != nil || length <= 0 || length > maxPDFStream {
+		return pdfDict{}, nil, nil, false
+	}
+	pos := off + int64(start+loc[1]+si+len("stream"))
+	data, ok := readRegion(nil, x.file, pos, int(min(x.file.size-pos, int64(length)+2)))
+	if !ok {
+		return pdfDict{}, nil, nil, false
+	}
+	data = bytes.TrimPrefix(data, []byte("\r"))
+	data = bytes.TrimPrefix(data, []byte("\n"))
+	if len(data) < length {
+		return pdfDict{}, nil, nil, false
+	}
+	return d, tokens, data[:length], true
+}
+
+// pages reads /Count from the root of the page tree the catalog points to.
+func (x *pdfXref) pages() (int, bool) {
+	catalog, ok := x.object(x.root)
+	if !ok || catalog.refs["/Pages"] == 0 {
+		return 0, false
+	}
+	tree, ok := x.object(catalog.refs["/Pages"])
+	if !ok || tree.values["/Type"] != "/Pages" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(tree.values["/Count"])
+	return n, err == nil && n >= 0
+}
+
+// pdfInts returns the integer, or the integers of the array, following the
+// first occurrence of key at any depth.
+func pdfInts(tokens []string, key string) []int {
+	for i, tok := range tokens {
+		if tok != key || i+1 >= len(tokens) {
+			continue
+		}
+		if tokens[i+1] != "[" {
+			if n, err := strconv.Atoi(tokens[i+1]); err == nil {
+				return []int{n}
+			}
+			return nil
+		}
+		var out []int
+		for _, t := range tokens[i+2:] {
+			n, err := strconv.Atoi(t)
+			if err != nil {
+				break
+			}
+			out = append(out, n)
+		}
+		return out
+	}
+	return nil
+}
+
+// pdfUnpredict undoes the PNG predictors (/Predictor 10 to 15) of rows of
+// columns one-byte samples, as cross-reference streams use them.
+func pdfUnpredict(data []byte, columns int) ([]byte, bool) {
+	if columns <= 0 || columns > len(data) {
+		return nil, false
+	}
+	abs := func(n int) int {
+		if n < 0 {
+			return -n
+		}
+		return n
+	}
+	out := make([]byte, 0, len(data))
+	prev := make([]byte, columns)
+	for len(data) > columns {
+		filter, row := data[0], data[1:columns+1]
+		data = data[columns+1:]
+		cur := make([]byte, columns)
+		for i, v := range row {
+			var left, upLeft byte
+			if i > 0 {
+				left, upLeft = cur[i-1], prev[i-1]
+			}
+			up := prev[i]
+			switch filter {
+			case 0:
+				cur[i] = v
+			case 1:
+				cur[i] = v + left
+			case 2:
+				cur[i] = v + up
+			case 3:
+				cur[i] = v + byte((int(left)+int(up))/2)
+			case 4:
+				p := int(left) + int(up) - int(upLeft)
+				pa, pb, pc := abs(p-int(left)), abs(p-int(up)), abs(p-int(upLeft))
+				switch {
+				case pa <= pb && pa <= pc:
+					cur[i] = v + left
+				case pb <= pc:
+					cur[i] = v + up
+				default:
+					cur[i] = v + upLeft
+				}
+			default:
+				return nil, false
+			}
+		}
+		out = append(out, cur...)
+		prev = cur
+	}
+	return out, true
+}
@@ -0,0 +1,468 @@
+package magic
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule is a declarative signature loaded at run time, so teams can teach fil
+// their own formats without recompiling. A rule matches when all of its
+// tests hold.
+type Rule struct {
+	Name string `json:"name" yaml:"name" toml:"name"`
+	// Description is a text/template; named tests are available as fields,
+	// e.g. "Acme archive, version {{.version}}".
+	Description string `json:"description" yaml:"description" toml:"description"`
+	MIME        string `json:"mime" yaml:"mime" toml:"mime"`
+	// Priority orders rules among themselves, highest first. Rules with a
+	// positive priority are tried before every built-in matcher; the others
+	// after the built-in signatures, just before the text and data fallbacks.
+	Priority   int    `json:"priority" yaml:"priority" toml:"priority"`
+	Confidence int    `json:"confidence" yaml:"confidence" toml:"confidence"`
+	Tests      []Test `json:"tests" yaml:"tests" toml:"tests"`
+}
+
+// Test is one condition of a Rule. It compares either a byte pattern (Hex or
+// String, optionally masked and searched for) or an integer (Int).
+type Test struct {
+	// Offset is where the test looks; negative offsets count from the end.
+	Offset int64 `json:"offset" yaml:"offset" toml:"offset"`
+
+	Hex    string `json:"hex" yaml:"hex" toml:"hex"`          // pattern as hex digits, spaces ignored
+	String string `json:"string" yaml:"string" toml:"string"` // pattern as literal bytes
+	Mask   string `json:"mask" yaml:"mask" toml:"mask"`       // hex mask ANDed with the data first
+	Search int    `json:"search" yaml:"search" toml:"search"` // find the pattern within this many bytes of Offset
+
+	Int     string `json:"int" yaml:"int" toml:"int"`          // u8, u16, u32 or u64
+	Endian  string `json:"endian" yaml:"endian" toml:"endian"` // little (default) or big
+	Op      string `json:"op" yaml:"op" toml:"op"`             // =, !=, <, >, & (all bits set); default =
+	Value   uint64 `json:"value" yaml:"value" toml:"value"`
+	IntMask uint64 `json:"int_mask" yaml:"int_mask" toml:"int_mask"`
+
+	// Name exposes the value read by the test to the description template.
+	Name string `json:"name" yaml:"name" toml:"name"`
+}
+
+// Rules is a compiled set of extra signatures, ready to pass in
+// Options.Rules. It comes from LoadRules or LoadMagicSource.
+type Rules struct {
+	count    int
+	early    []fileMatcher // tried before every built-in matcher
+	late     []fileMatcher // tried before the text and data fallbacks
+	warnings []string
+	matchers []fileMatcher
+}
+
+func newRules(count int, early, late []fileMatcher, warnings []string) *Rules {
+	rs := &Rules{count: count, early: early, late: late, warnings: warnings}
+	rs.matchers = make([]fileMatcher, 0, len(matchers)+len(early)+len(late))
+	rs.matchers = append(rs.matchers, early...)
+	for _, m := range matchers {
+		if m.name == "text" {
+			rs.matchers = append(rs.matchers, late...)
+		}
+		rs.matchers = append(rs.matchers, m)
+	}
+	return rs
+}
+
+// Merge returns a set holding the rules of rs and other, ordered by
+// priority as if they had been compiled together; among equal priorities
+// the rules of rs come first. Either may be nil.
+func (rs *Rules) Merge(other *Rules) *Rules {
+	if rs == nil {
+		return other
+	}
+	if other == nil {
+		return rs
+	}
+	byPriority := func(a, b []fileMatcher) []fileMatcher {
+		all := append(append([]fileMatcher(nil), a...), b...)
+		sort.SliceStable(all, func(i, j int) bool {
+			return all[i].priority > all[j].priority
+		})
+		return all
+	}
+	return newRules(rs.count+other.count,
+		byPriority(rs.early, other.early),
+		byPriority(rs.late, other.late),
+		append(append([]string(nil), rs.warnings...), other.warnings...))
+}
+
+// Len returns the number of rules in the set.
+func (rs *Rules) Len() int {
+	if rs == nil {
+		return 0
+	}
+	return rs.count
+}
+
+// Warnings describes the parts of the source that were skipped, such as
+// magic(5) lines using types fil does not implement.
+func (rs *Rules) Warnings() []string {
+	if rs == nil {
+		return nil
+	}
+	return rs.warnings
+}
+
+// matcherList returns the matcher order to use for a detection.
+func (rs *Rules) matcherList() []fileMatcher {
+	if rs == nil {
+		return matchers
+	}
+	return rs.matchers
+}
+
+type ruleFile struct {
+	Rules []Rule `json:"rules" yaml:"rules" toml:"rules"`
+}
+
+// LoadRules reads rules from a file; the extension picks the format (.json,
+// .yaml/.yml or .toml). The file holds a top-level "rules" list.
+func LoadRules(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	rules, err := ParseRules(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// ParseRules decodes and compiles rules in the given format: "json", "yaml"
+// or "toml".
+func ParseRules(data []byte, format string) (*Rules, error) {
+	var rf ruleFile
+	var err error
+	switch format {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&rf)
+	case "yaml", "yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&rf)
+	case "toml":
+		var md toml.MetaData
+		md, err = toml.Decode(string(data), &rf)
+		if err == nil {
+			if undecoded := md.Undecoded(); len(undecoded) > 0 {
+				err = fmt.Errorf("unknown field %q", undecoded[0].String())
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported rules format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return CompileRules(rf.Rules)
+}
+
+// CompileRules validates rules and builds the matcher order they run in.
+func CompileRules(rules []Rule) (*Rules, error) {
+	sorted := append([]Rule(nil), rules...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority > sorted[j].Priority
+	})
+
+	var early, late []fileMatcher
+	for i, r := range sorted {
+		m, err := r.compile()
+		if err != nil {
+			name := r.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			return nil, fmt.Errorf("rule %s: %w", name, err)
+		}
+		if r.Priority > 0 {
+			early = append(early, m)
+		} else {
+			late = append(late, m)
+		}
+	}
+	return newRules(len(sorted), early, late, nil), nil
+}
+
+type compiledTest struct {
+	Test
+	pattern []byte
+	mask    []byte
+	size    int
+	big     bool
+}
+
+func (r Rule) compile() (fileMatcher, error) {
+	if len(r.Tests) == 0 {
+		return fileMatcher{}, fmt.Errorf("no tests")
+	}
+	if r.Description == "" {
+		return fileMatcher{}, fmt.Errorf("no description")
+	}
+	tmpl, err := template.New(r.Name).Option("missingkey=zero").Parse(r.Description)
+	if err != nil {
+		return fileMatcher{}, err
+	}
+
+	tests := make([]compiledTest, len(r.Tests))
+	minLen := 0
+	for i, t := range r.Tests {
+		ct, err := t.compile()
+		if err != nil {
+			return fileMatcher{}, fmt.Errorf("test %d: %w", i+1, err)
+		}
+		tests[i] = ct
+		if t.Offset >= 0 && t.Search == 0 {
+			if end := int(t.Offset) + ct.width(); end > minLen && end <= MaxBytesToRead {
+				minLen = end
+			}
+		}
+	}
+
+	name := r.Name
+	if name == "" {
+		name = "rule"
+	}
+	run := func(b []byte, file *source) (map[string]any, bool) {
+		values := map[string]any{}
+		for _, t := range tests {
+			v, ok := t.eval(b, file)
+			if !ok {
+				return nil, false
+			}
+			if t.Name != "" {
+				values[t.Name] = v
+			}
+		}
+		return values, true
+	}
+	return fileMatcher{
+		name:       name,
+		minLen:     minLen,
+		mime:       r.MIME,
+		confidence: r.Confidence,
+		priority:   r.Priority,
+		eval: func(b []byte, file *source) (string, string, bool) {
+			values, ok := run(b, file)
+			if !ok {
+				return "", "", false
+			}
+			var out strings.Builder
+			if err := tmpl.Execute(&out, values); err != nil {
+				return r.Description, "", true
+			}
+			return out.String(), "", true
+		},
+	}, nil
+}
+
+func (t Test) compile() (compiledTest, error) {
+	ct := compiledTest{Test: t}
+	switch {
+	case t.Int != "":
+		if t.Hex != "" || t.String != "" {
+			return ct, fmt.Errorf("int test cannot also have a byte pattern")
+		}
+		switch t.Int {
+		case "u8":
+			ct.size = 1
+		case "u16":
+			ct.size = 2
+		case "u32":
+			ct.size = 4
+		case "u64":
+			ct.size = 8
+		default:
+			return ct, fmt.Errorf("unknown int type %q", t.Int)
+		}
+		switch t.Endian {
+		case "", "little", "le":
+		case "big", "be":
+			ct.big = true
+		default:
+			return ct, fmt.Errorf("unknown endian %q", t.Endian)
+		}
+		switch t.Op {
+		case "", "=", "!=", "<", ">", "&":
+		default:
+			return ct, fmt.Errorf("unknown op %q", t.Op)
+		}
+	case t.Hex != "" && t.String != "":
+		return ct, fmt.Errorf("hex and string are exclusive")
+	case t.Hex != "":
+		p, err := decodeHexPattern(t.Hex)
+		if err != nil {
+			return ct, fmt.Errorf("hex: %w", err)
+		}
+		ct.pattern = p
+	case t.String != "":
+		ct.pattern = []byte(t.String)
+	default:
+		return ct, fmt.Errorf("needs hex, string or int")
+	}
+	if t.Mask != "" {
+		if ct.pattern == nil {
+			return ct, fmt.Errorf("mask applies to byte patterns; use int_mask")
+		}
+		m, err := decodeHexPattern(t.Mask)
+		if err != nil {
+			return ct, fmt.Errorf("mask: %w", err)
+		}
+		if len(m) != len(ct.pattern) {
+			return ct, fmt.Errorf("mask is %d bytes, pattern is %d", len(m), len(ct.pattern))
+		}
+		ct.mask = m
+	}
+	if t.Search < 0 {
+		return ct, fmt.Errorf("negative search window")
+	}
+	return ct, nil
+}
+
+func decodeHexPattern(s string) ([]byte, error) {
+	s = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, s)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	return hex.DecodeString(s)
+}
+
+// width is the number of bytes a test reads at its offset.
+func (t compiledTest) width() int {
+	if t.size > 0 {
+		return t.size
+	}
+	return len(t.pattern) + t.Search
+}
+
+func (t compiledTest) eval(b []byte, file *source) (any, bool) {
+	data, ok := readRuleRegion(b, file, t.Offset, t.width())
+	if !ok {
+		// A search window may run past the end of the input.
+		if t.Search == 0 {
+			return nil, false
+		}
+		if data, ok = readRuleRegion(b, file, t.Offset, -1); !ok {
+			return nil, false
+		}
+	}
+
+	if t.size > 0 {
+		var v uint64
+		if t.big {
+			v = uint64(peekBe(data, t.size))
+		} else {
+			v = uint64(peekLe(data, t.size))
+		}
+		if t.IntMask != 0 {
+			v &= t.IntMask
+		}
+		switch t.Op {
+		case "", "=":
+			ok = v == t.Value
+		case "!=":
+			ok = v != t.Value
+		case "<":
+			ok = v < t.Value
+		case ">":
+			ok = v > t.Value
+		case "&":
+			ok = v&t.Value == t.Value
+		}
+		return v, ok
+	}
+
+	for i := 0; i+len(t.pattern) <= len(data); i++ {
+		if patternAt(data[i:], t.pattern, t.mask) {
+			return strings.TrimRight(string(data[i:i+len(t.pattern)]), "\x00"), true
+		}
+		if t.Search == 0 {
+			break
+		}
+	}
+	return nil, false
+}
+
+func patternAt(data, pattern, mask []byte) bool {
+	for i, p := range pattern {
+		d := data[i]
+		if mask != nil {
+			d &= mask[i]
+			p &= mask[i]
+		}
+		if d != p {
+			return false
+		}
+	}
+	return true
+}
+
+// readRegion returns n bytes at off, from the leading buffer when it covers
+// them and from the file otherwise. With n < 0 it returns everything from off
+// to the end of the available data. Offsets usually come from file headers,
+// so negative ones and ones past the end fail rather than wrap.
+func readRegion(b []byte, file *source, off int64, n int) ([]byte, bool) {
+	size := inputSize(b, file)
+	if off < 0 || off > size {
+		return nil, false
+	}
+	if n < 0 {
+		n = int(min(size-off, MaxBytesToRead))
+		if n <= 0 {
+			return nil, false
+		}
+	}
+	if int64(n) > size-off {
+		return nil, false
+	}
+	end := off + int64(n)
+	if end <= int64(len(b)) {
+		return b[off:end], true
+	}
+	if file == nil {
+		return nil, false
+	}
+	buf := make([]byte, n)
+	if _, err := file.ReadAt(buf, off); err != nil && err != io.EOF {
+		return nil, false
+	}
+	return buf, true
+}
+
+// readRuleRegion is readRegion for offsets written in a rule, where a
+// negative offset counts back from the end of the input.
+func readRuleRegion(b []byte, file *source, off int64, n int) ([]byte, bool) {
+	if off < 0 {
+		off += inputSize(b, file)
+	}
+	return readRegion(b, file, off, n)
+}
+
+// inputSize is the size of the whole input, or of the leading buffer when
+// that is all there is.
+func inputSize(b []byte, file *source) int64 {
+	if file != nil {
+		return file.size
+	}
+	return int64(len(b))
+}
@@ -0,0 +1,184 @@
+package magic
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// sqliteApplications names the owners of registered SQLite application_id
+// values (header offset 68), after SQLite's own magic.txt.
+var sqliteApplications = map[uint32]string{
+	0x0F055111: "Fossil repository",
+	0x0F055112: "Fossil checkout",
+	0x0F055113: "Fossil global configuration",
+	0x42654462: "Bentley Systems BeSQLite database",
+	0x42654C6E: "Bentley Systems localization file",
+	0x45737269: "Esri spatially-enabled database",
+	0x47503130: "OGC GeoPackage 1.0",
+	0x47503131: "OGC GeoPackage 1.1",
+	0x47504B47: "OGC GeoPackage",
+	0x4D504248: "MBTiles tileset",
+}
+
+// sqliteSchemas names applications that leave application_id at zero by a
+// table their schema always creates, looked for in the first page (where
+// sqlite_master starts).
+var sqliteSchemas = []struct {
+	table string
+	name  string
+}{
+	{"moz_places", "Firefox places"},
+	{"moz_cookies", "Firefox cookies"},
+	{"moz_formhistory", "Firefox form history"},
+	{"moz_perms", "Firefox permissions"},
+	{"keyword_search_terms", "Chromium history"},
+	{"autofill_profiles", "Chromium web data"},
+	{"Z_METADATA", "Apple Core Data store"},
+	{"android_metadata", "Android database"},
+}
+
+var sqliteEncodings = map[int]string{1: "UTF-8", 2: "UTF-16le", 3: "UTF-16be"}
+
+// sqliteHeader is the part of the 100-byte database header fil reports.
+type sqliteHeader struct {
+	pageSize      int
+	writeVersion  int // 1 rollback journal, 2 WAL
+	pages         int
+	encoding      int
+	userVersion   int
+	appID         uint32
+	sqliteVersion int // SQLITE_VERSION_NUMBER of the last writer
+}
+
+func parseSQLiteHeader(b []byte, file *source) sqliteHeader {
+	var h sqliteHeader
+	if len(b) >= 18 {
+		// Page size 1 means 65536.
+		h.pageSize = peekBe(b[16:], 2)
+		if h.pageSize == 1 {
+			h.pageSize = 65536
+		}
+	}
+	if len(b) < 100 {
+		return h
+	}
+	h.writeVersion = int(b[18])
+	h.encoding = peekBe(b[56:], 4)
+	h.userVersion = int(int32(peekBe(b[60:], 4)))
+	h.appID = uint32(peekBe(b[68:], 4))
+	h.sqliteVersion = peekBe(b[96:], 4)
+
+	// The in-header page count is only trusted when the change counter
+	// matches version-valid-for, i.e. it was written by SQLite 3.7.0+.
+	if n := peekBe(b[28:], 4); n > 0 && peekBe(b[24:], 4) == peekBe(b[92:], 4) {
+		h.pages = n
+	} else if file != nil && h.pageSize > 0 {
+		h.pages = int(file.size / int64(h.pageSize))
+	}
+	return h
+}
+
+// sqliteApplication names the application owning the database, from its
+// application_id or, failing that, its schema.
+func sqliteApplication(b []byte, file *source, h sqliteHeader) string {
+	if name := sqliteApplications[h.appID]; name != "" || h.appID != 0 {
+		return name
+	}
+	if h.pageSize == 0 {
+		return ""
+	}
+	page, ok := readRegion(b, file, 0, h.pageSize)
+	if !ok {
+		page = b
+	}
+	for _, s := range sqliteSchemas {
+		for _, sql := range []string{"CREATE TABLE " + s.table + " ", "CREATE TABLE " + s.table + "(", "CREATE TABLE \"" + s.table + "\""} {
+			if bytes.Contains(page, sqliteText(sql, h.encoding)) {
+				return s.name
+			}
+		}
+	}
+	return ""
+}
+
+// sqliteText encodes ASCII text as the database stores it.
+func sqliteText(s string, encoding int) []byte {
+	if encoding != 2 && encoding != 3 {
+		return []byte(s)
+	}
+	out := make([]byte, 0, 2*len(s))
+	for i := 0; i < len(s); i++ {
+		if encoding == 2 {
+			out = append(out, s[i], 0)
+		} else {
+			out = append(out, 0, s[i])
+		}
+	}
+	return out
+}
+
+// sqliteVersionString renders SQLITE_VERSION_NUMBER (X*1000000 + Y*1000 +
+// Z) as X.Y.Z.
+func sqliteVersionString(v int) string {
+	return fmt.Sprintf("%d.%d.%d", v/1000000, v/1000%1000, v%1000)
+}
+
+// describeSQLiteAs reports the header of a SQLite database under the given
+// name: "SQLite database (Fossil repository), page size 4096, 120 pages,
+// UTF-8, WAL mode, user_version 3, last written by SQLite 3.45.1".
+func describeSQLiteAs(base string, nameApp bool) func([]byte, *source) (string, map[string]any) {
+	return func(b []byte, file *source) (string, map[string]any) {
+		h := parseSQLiteHeader(b, file)
+		f := map[string]any{}
+		var out strings.Builder
+		out.WriteString(base)
+
+		app := sqliteApplication(b, file, h)
+		if app != "" {
+			f["application"] = app
+			if nameApp {
+				out.WriteString(" (" + app + ")")
+			}
+		}
+		if h.appID != 0 {
+			f["application_id"] = fmt.Sprintf("0x%08x", h.appID)
+			if app == "" {
+				fmt.Fprintf(&out, ", application_id 0x%08x", h.appID)
+			}
+		}
+		if h.pageSize > 0 {
+			fmt.Fprintf(&out, ", page size %d", h.pageSize)
+			f["page_size"] = h.pageSize
+		}
+		if h.pages > 0 {
+			fmt.Fprintf(&out, ", %d pages", h.pages)
+			f["pages"] = h.pages
+		}
+		if enc := sqliteEncodings[h.encoding]; enc != "" {
+			out.WriteString(", " + enc)
+			f["encoding"] = enc
+		}
+		switch h.writeVersion {
+		case 1:
+			out.WriteString(", rollback journal mode")
+			f["journal_mode"] = "rollback"
+		case 2:
+			out.WriteString(", WAL mode")
+			f["journal_mode"] = "wal"
+		}
+		if h.userVersion != 0 {
+			fmt.Fprintf(&out, ", user_version %d", h.userVersion)
+			f["user_version"] = h.userVersion
+		}
+		if h.sqliteVersion >= 3000000 && h.sqliteVersion < 4000000 {
+			v := sqliteVersionString(h.sqliteVersion)
+			out.WriteString(", last written by SQLite " + v)
+			f["sqlite_version"] = v
+		}
+		if len(f) == 0 {
+			f = nil
+		}
+		return out.String(), f
+	}
+}
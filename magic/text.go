@@ -0,0 +1,320 @@
+package magic
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+func isText(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+
+	if _, _, ok := decodeUTF16Text(b); ok {
+		return true
+	}
+
+	// Some Windows batch files are saved in legacy 8-bit encodings (for example
+	// cp1252 smart punctuation), so they fail strict UTF-8 validation despite
+	// being clearly text.
+	if looksLikeLegacyBatchText(b) {
+		return true
+	}
+	// Legacy-encoded scripts with shebangs (for example perl/shell) may be
+	// non-UTF but still should be classified as text.
+	if looksLikeLegacyShebangText(b) {
+		return true
+	}
+	// Some plain text files are encoded in legacy 8-bit encodings (ISO-8859/cp1252).
+	if looksLikeLegacy8BitText(b) {
+		return true
+	}
+
+	return isUTF8LikeText(b)
+}
+
+func looksLikeLegacyBatchText(b []byte) bool {
+	if len(b) == 0 || utf8.Valid(b) {
+		return false
+	}
+	if bytes.IndexByte(b, 0x00) >= 0 {
+		return false
+	}
+
+	const maxScan = 32 * 1024
+	end := len(b)
+	if end > maxScan {
+		end = maxScan
+	}
+
+	topLower := "\n" + strings.ToLower(string(b[:end]))
+	return looksLikeBatch(topLower)
+}
+
+func looksLikeLegacyShebangText(b []byte) bool {
+	if len(b) < 4 || utf8.Valid(b) {
+		return false
+	}
+	if !bytes.HasPrefix(b, []byte("#!")) {
+		return false
+	}
+	if bytes.IndexByte(b, 0x00) >= 0 {
+		return false
+	}
+
+	end := len(b)
+	if end > 4096 {
+		end = 4096
+	}
+	headLower := strings.ToLower(string(b[:end]))
+	if !(strings.Contains(headLower, "perl") || strings.Contains(headLower, "/sh") || strings.Contains(headLower, "bash")) {
+		return false
+	}
+
+	badCtrl := 0
+	for _, c := range b[:end] {
+		if c < 0x20 && c != '\n' && c != '\r' && c != '\t' && c != '\f' && c != '\b' {
+			badCtrl++
+		}
+	}
+	return badCtrl == 0 || badCtrl*100/end <= 2
+}
+
+func looksLikeLegacy8BitText(b []byte) bool {
+	if len(b) < 16 || utf8.Valid(b) {
+		return false
+	}
+	if bytes.IndexByte(b, 0x00) >= 0 {
+		return false
+	}
+
+	end := len(b)
+	if end > 16*1024 {
+		end = 16 * 1024
+	}
+	s := b[:end]
+
+	badCtrl := 0
+	printable := 0
+	high := 0
+	for _, c := range s {
+		if c >= 0x80 {
+			high++
+			printable++
+			continue
+		}
+		if c >= 0x20 || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == '\b' {
+			printable++
+			continue
+		}
+		badCtrl++
+	}
+	if high == 0 {
+		return false
+	}
+	// Keep strict to avoid classifying binary blobs as text.
+	if badCtrl*100/end > 1 {
+		return false
+	}
+	return printable*100/end >= 90
+}
+
+func isUTF8LikeText(b []byte) bool {
+	badCtrl := 0
+	for _, c := range b {
+		if c == 0x00 {
+			return false
+		}
+		if c < 0x20 && c != '\n' && c != '\r' && c != '\t' && c != '\f' && c != '\b' {
+			badCtrl++
+		}
+	}
+
+	if badCtrl > 0 && badCtrl*100/len(b) > 2 {
+		return false
+	}
+
+	if isASCIIOnly(b) {
+		return true
+	}
+	return utf8.Valid(b)
+}
+
+func isASCIIOnly(b []byte) bool {
+	for _, c := range b {
+		if c >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+func describeText(b []byte) string {
+	if label, decoded, ok := decodeUTF16Text(b); ok {
+		base := "Unicode text, " + label + " text"
+		subtype := detectTextSubtype(decoded)
+		if subtype != "" {
+			base += ", " + subtype
+		}
+		if endings := detectLineEndings(decoded); endings != "" {
+			base += ", with " + endings + " line terminators"
+		}
+		return base
+	}
+
+	base := "UTF-8 text"
+	if isASCIIOnly(b) {
+		base = "ASCII text"
+	} else if !utf8.Valid(b) {
+		base = "Non-UTF text"
+	}
+
+	subtype := detectTextSubtype(b)
+	if subtype != "" {
+		base += ", " + subtype
+	}
+	if endings := detectLineEndings(b); endings != "" {
+		base += ", with " + endings + " line terminators"
+	}
+	return base
+}
+
+func decodeUTF16Text(b []byte) (string, []byte, bool) {
+	if len(b) < 4 {
+		return "", nil, false
+	}
+
+	if len(b) >= 2 && b[0] == 0xFF && b[1] == 0xFE {
+		decoded, ok := decodeUTF16Bytes(b[2:], true)
+		if ok {
+			return "UTF-16, little-endian", decoded, true
+		}
+		return "", nil, false
+	}
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		decoded, ok := decodeUTF16Bytes(b[2:], false)
+		if ok {
+			return "UTF-16, big-endian", decoded, true
+		}
+		return "", nil, false
+	}
+
+	switch utf16EndiannessHeuristic(b) {
+	case "le":
+		decoded, ok := decodeUTF16Bytes(b, true)
+		if ok {
+			return "UTF-16, little-endian", decoded, true
+		}
+	case "be":
+		decoded, ok := decodeUTF16Bytes(b, false)
+		if ok {
+			return "UTF-16, big-endian", decoded, true
+		}
+	}
+
+	return "", nil, false
+}
+
+func decodeUTF16Bytes(b []byte, littleEndian bool) ([]byte, bool) {
+	n := len(b)
+	if n < 2 {
+		return nil, false
+	}
+	if n%2 != 0 {
+		n--
+	}
+	if n < 2 {
+		return nil, false
+	}
+
+	u16s := make([]uint16, 0, n/2)
+	for i := 0; i+1 < n; i += 2 {
+		var v uint16
+		if littleEndian {
+			v = uint16(b[i]) | uint16(b[i+1])<<8
+		} else {
+			v = uint16(b[i])<<8 | uint16(b[i+1])
+		}
+		u16s = append(u16s, v)
+	}
+
+	runes := utf16.Decode(u16s)
+	if len(runes) == 0 {
+		return nil, false
+	}
+	text := string(runes)
+	if len(text) == 0 || !isUTF8LikeText([]byte(text)) {
+		return nil, false
+	}
+	return []byte(text), true
+}
+
+func utf16EndiannessHeuristic(b []byte) string {
+	end := len(b)
+	if end > 4096 {
+		end = 4096
+	}
+	if end%2 != 0 {
+		end--
+	}
+	if end < 8 {
+		return ""
+	}
+
+	pairs := end / 2
+	oddZero := 0
+	evenZero := 0
+	lePrintable := 0
+	bePrintable := 0
+
+	for i := 0; i < end; i += 2 {
+		lo := b[i]
+		hi := b[i+1]
+		if hi == 0 {
+			oddZero++
+			if isLikelyTextByte(lo) {
+				lePrintable++
+			}
+		}
+		if lo == 0 {
+			evenZero++
+			if isLikelyTextByte(hi) {
+				bePrintable++
+			}
+		}
+	}
+
+	if oddZero*100/pairs >= 55 && lePrintable*100/pairs >= 50 {
+		return "le"
+	}
+	if evenZero*100/pairs >= 55 && bePrintable*100/pairs >= 50 {
+		return "be"
+	}
+	return ""
+}
+
+func isLikelyTextByte(c byte) bool {
+	return c == '\n' || c == '\r' || c == '\t' || (c >= 0x20 && c <= 0x7E)
+}
+
+func detectLineEndings(b []byte) string {
+	hasCRLF := bytes.Contains(b, []byte("\r\n"))
+	withoutCRLF := bytes.ReplaceAll(b, []byte("\r\n"), []byte{})
+	hasLF := bytes.Contains(withoutCRLF, []byte("\n"))
+	hasCR := bytes.Contains(withoutCRLF, []byte("\r"))
+
+	switch {
+	case hasCRLF && !hasLF && !hasCR:
+		return "CRLF"
+	case !hasCRLF && hasLF && !hasCR:
+		return "LF"
+	case !hasCRLF && !hasLF && hasCR:
+		return "CR"
+	case hasCRLF || hasLF || hasCR:
+		return "mixed"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,227 @@
+package magic
+
+import (
+	"bytes"
+	"debug/buildinfo"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// maxToolchainScan bounds how much of each read-only data section is
+// searched for toolchain fingerprints.
+const maxToolchainScan = 4 << 20
+
+var (
+	goBuildInfoMagic = []byte("\xff Go buildinf:")
+	rustcPathMarker  = []byte("/rustc/")
+	gccMarker        = []byte("GCC: (")
+	clangMarker      = []byte("clang version ")
+)
+
+// execSection is where a named section of an ELF, PE or Mach-O file lies.
+type execSection struct {
+	name      string
+	off, size int
+}
+
+// goBuildInfoSections may start with the Go buildinfo blob: its own section
+// in ELF and Mach-O files, the first writable data section in PE files.
+var goBuildInfoSections = map[string]bool{".go.buildinfo": true, "__go_buildinfo": true, ".data": true}
+
+// toolchainDataSections hold the read-only data where Rust panic locations
+// and compiler version strings end up.
+var toolchainDataSections = map[string]bool{".rodata": true, ".rdata": true, "__const": true, "__cstring": true}
+
+// toolchainInfo is what scanToolchain finds in an ELF, PE or Mach-O file.
+type toolchainInfo struct {
+	goVersion string // "go1.22.1"
+	goPath    string // main package
+	goModule  string // main module path and version
+
+	rust        bool
+	rustcCommit string // from "/rustc/<hash>/library/..." panic locations
+
+	compilers []string // "GCC: (Debian 12.2.0-14) 12.2.0", "clang version 15.0.7"
+}
+
+// scanToolchain looks for the Go buildinfo blob, Rust panic locations and
+// GCC/Clang version strings in the sections of an executable that hold
+// them, rather than reading the whole file. An ELF .comment section, when
+// present, is the only place compilers are taken from.
+func scanToolchain(b []byte, file *source, sections []execSection) toolchainInfo {
+	var info toolchainInfo
+	hasComment := slices.ContainsFunc(sections, func(s execSection) bool { return s.name == ".comment" })
+	goFound := false
+	for _, sec := range sections {
+		if sec.size <= 0 {
+			continue
+		}
+		switch {
+		case goBuildInfoSections[sec.name] && !goFound:
+			// The blob is 16-byte aligned at the start of its section.
+			if data, ok := readAt(b, file, sec.off, min(sec.size, 64<<10)); ok && bytes.Contains(data, goBuildInfoMagic) {
+				goFound = true
+			}
+		case sec.name == ".comment":
+			if data, ok := readAt(b, file, sec.off, min(sec.size, 1<<16)); ok {
+				info.addCompilers(data)
+			}
+		case toolchainDataSections[sec.name]:
+			info.scanData(b, file, sec, !hasComment)
+		}
+	}
+
+	if goFound {
+		var r io.ReaderAt = bytes.NewReader(b)
+		if file != nil {
+			r = file
+		}
+		if bi, err := buildinfo.Read(r); err == nil {
+			info.goVersion = bi.GoVersion
+			info.goPath = bi.Path
+			if bi.Main.Path != "" {
+				info.goModule = strings.TrimSpace(bi.Main.Path + " " + bi.Main.Version)
+			}
+		}
+	}
+	return info
+}
+
+// scanData searches up to maxToolchainScan bytes of a read-only data
+// section for Rust panic locations and, with compilers, version strings.
+func (info *toolchainInfo) scanData(b []byte, file *source, sec execSection, compilers bool) {
+	const chunkSize = 1 << 20
+	const overlap = 128 // longest marker plus the text we keep after it
+	end := sec.off + min(sec.size, maxToolchainScan)
+	for off := sec.off; off < end; off += chunkSize - overlap {
+		chunk, ok := readAt(b, file, off, min(chunkSize, end-off))
+		if !ok {
+			return
+		}
+		if info.rustcCommit == "" {
+			info.findRustc(chunk)
+		}
+		if compilers {
+			info.addCompilers(chunk)
+		}
+	}
+}
+
+func (info *toolchainInfo) findRustc(chunk []byte) {
+	for rest := chunk; ; {
+		i := bytes.Index(rest, rustcPathMarker)
+		if i < 0 {
+			break
+		}
+		rest = rest[i+len(rustcPathMarker):]
+		if len(rest) >= 41 && isHexString(rest[:40]) && rest[40] == '/' {
+			info.rust = true
+			info.rustcCommit = string(rest[:40])
+			return
+		}
+	}
+	if bytes.Contains(chunk, []byte("rust_panic")) || bytes.Contains(chunk, []byte("RUST_BACKTRACE")) {
+		info.rust = true
+	}
+}
+
+// addCompilers collects NUL-terminated GCC and Clang identification strings.
+func (info *toolchainInfo) addCompilers(data []byte) {
+	for _, marker := range [][]byte{gccMarker, clangMarker} {
+		for rest := data; len(info.compilers) < 4; {
+			i := bytes.Index(rest, marker)
+			if i < 0 {
+				break
+			}
+			// Vendor prefixes such as "Apple clang version" belong to the string.
+			start := bytes.LastIndexByte(rest[:i], 0) + 1
+			if i-start > 32 {
+				start = i
+			}
+			end := bytes.IndexByte(rest[i:], 0)
+			if end < 0 || end > 96 {
+				rest = rest[i+len(marker):]
+				continue
+			}
+			s := strings.TrimSpace(string(rest[start : i+end]))
+			if isPrintableASCII(s) && !slices.Contains(info.compilers, s) {
+				info.compilers = append(info.compilers, s)
+			}
+			rest = rest[i+end:]
+		}
+	}
+}
+
+func (info toolchainInfo) describe() string {
+	var output strings.Builder
+	if info.goVersion != "" {
+		output.WriteString(", Go " + strings.TrimPrefix(info.goVersion, "go"))
+		if info.goModule != "" {
+			output.WriteString(" (" + info.goModule + ")")
+		} else if info.goPath != "" {
+			output.WriteString(" (" + info.goPath + ")")
+		}
+	}
+	if info.rust {
+		output.WriteString(", Rust")
+		if info.rustcCommit != "" {
+			output.WriteString(" (rustc " + info.rustcCommit[:9] + ")")
+		}
+	}
+	for _, c := range info.compilers {
+		fmt.Fprintf(&output, ", %s", c)
+	}
+	return output.String()
+}
+
+// fields returns the toolchain as Result.Fields, nil when nothing was found.
+func (info toolchainInfo) fields() map[string]any {
+	f := map[string]any{}
+	info.addFields(f)
+	if len(f) == 0 {
+		return nil
+	}
+	return f
+}
+
+// addFields records the toolchain in a Result.Fields map.
+func (info toolchainInfo) addFields(f map[string]any) {
+	if info.goVersion != "" {
+		f["go_version"] = info.goVersion
+		if info.goPath != "" {
+			f["go_path"] = info.goPath
+		}
+		if info.goModule != "" {
+			f["go_module"] = info.goModule
+		}
+	}
+	if info.rust {
+		f["rust"] = true
+		if info.rustcCommit != "" {
+			f["rustc_commit"] = info.rustcCommit
+		}
+	}
+	if len(info.compilers) > 0 {
+		f["compilers"] = info.compilers
+	}
+}
+
+func isHexString(b []byte) bool {
+	for _, c := range b {
+		if !isHexDigit(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func isPrintableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package magic
+
+import "encoding/binary"
+
+// decompressXpressHuffman decodes the LZ77+Huffman variant of Microsoft's
+// Xpress compression (MS-XCA 2.1), stopping after outLen bytes. Each 64KiB
+// of output has its own 256-byte table of 4-bit code lengths for 512
+// symbols: 256 literals and 256 match headers.
+func decompressXpressHuffman(in []byte, outLen int) ([]byte, bool) {
+	out := make([]byte, 0, outLen)
+	pos := 0
+	word := func() uint32 {
+		// The encoder pads the end of the stream; treat what is missing
+		// as zero bits rather than failing on the last few symbols.
+		if pos+2 > len(in) {
+			pos += 2
+			return 0
+		}
+		pos += 2
+		return uint32(binary.LittleEndian.Uint16(in[pos-2:]))
+	}
+
+	for len(out) < outLen {
+		if pos+256 > len(in) {
+			return nil, false
+		}
+		table, ok := xpressDecodingTable(in[pos : pos+256])
+		if !ok {
+			return nil, false
+		}
+		pos += 256
+		bits := word()<<16 | word()
+		extra := 16
+		consume := func(n int) {
+			bits <<= n
+			if extra -= n; extra < 0 {
+				bits |= word() << -extra
+				extra += 16
+			}
+		}
+
+		blockEnd := len(out) + 65536
+		for len(out) < blockEnd && len(out) < outLen {
+			if pos > len(in)+4 {
+				return nil, false
+			}
+			entry := table[bits>>17]
+			sym, n := int(entry>>4), int(entry&0x0F)
+			if n == 0 {
+				return nil, false // a code the table leaves unassigned
+			}
+			consume(n)
+			if sym < 256 {
+				out = append(out, byte(sym))
+				continue
+			}
+
+			sym -= 256
+			length, offsetBits := sym&0x0F, sym>>4
+			if length == 15 {
+				if pos >= len(in) {
+					return nil, false
+				}
+				length = int(in[pos])
+				pos++
+				if length == 255 {
+					if pos+2 > len(in) {
+						return nil, false
+					}
+					length = int(binary.LittleEndian.Uint16(in[pos:]))
+					pos += 2
+					if length == 0 {
+						if pos+4 > len(in) {
+							return nil, false
+						}
+						length = int(binary.LittleEndian.Uint32(in[pos:]))
+						pos += 4
+					}
+					if length < 15 {
+						return nil, false
+					}
+					length -= 15
+				}
+				length += 15
+			}
+			length += 3
+
+			offset := 1 << offsetBits
+			if offsetBits > 0 {
+				offset += int(bits >> (32 - offsetBits))
+				consume(offsetBits)
+			}
+			if offset > len(out) {
+				return nil, false
+			}
+			for i := 0; i < length && len(out) < outLen; i++ {
+				out = append(out, out[len(out)-offset])
+			}
+		}
+	}
+	return out, true
+}
+
+// xpressDecodingTable expands the code lengths into a table indexed by the
+// next 15 bits of input, each entry holding symbol<<4 | code length. Codes
+// are canonical: assigned in order of length, then symbol.
+func xpressDecodingTable(lengths []byte) ([]uint16, bool) {
+	table := make([]uint16, 1<<15)
+	next := 0
+	for n := 1; n <= 15; n++ {
+		for sym := 0; sym < 512; sym++ {
+			if int(lengths[sym/2]>>(4*(sym%2))&0x0F) != n {
+				continue
+			}
+			span := 1 << (15 - n)
+			if next+span > len(table) {
+				return nil, false
+			}
+			for i := next; i < next+span; i++ {
+				table[i] = uint16(sym<<4 | n)
+			}
+			next += span
+		}
+	}
+	return table, next > 0
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+)
+
+// console is where the lines for one path are written. Pool workers give each
+// job its own buffered console so results for different files never
+// interleave.
+type console struct {
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func stdConsole() console {
+	return console{stdout: os.Stdout, stderr: os.Stderr}
+}
+
+type job struct {
+	fn     func(console)
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+	done   chan struct{}
+}
+
+// pool runs jobs on a bounded set of workers. With keepOrder, output is
+// written in submission order; otherwise each job is written as it finishes.
+// A pool with a single worker runs jobs inline on the caller's goroutine.
+type pool struct {
+	workers   int
+	keepOrder bool
+	out       console
+
+	work    chan *job
+	ordered chan *job
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	flushed chan struct{}
+}
+
+func newPool(workers int, keepOrder bool, out console) *pool {
+	p := &pool{workers: workers, keepOrder: keepOrder, out: out}
+	if workers <= 1 {
+		return p
+	}
+
+	p.work = make(chan *job)
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for j := range p.work {
+				j.fn(console{stdout: &j.stdout, stderr: &j.stderr})
+				if p.keepOrder {
+					close(j.done)
+					continue
+				}
+				p.mu.Lock()
+				p.flush(j)
+				p.mu.Unlock()
+			}
+		}()
+	}
+
+	if keepOrder {
+		// The ordered queue bounds how far workers may run ahead of the
+		// slowest unfinished job.
+		p.ordered = make(chan *job, workers*4)
+		p.flushed = make(chan struct{})
+		go func() {
+			for j := range p.ordered {
+				<-j.done
+				p.flush(j)
+			}
+			close(p.flushed)
+		}()
+	}
+	return p
+}
+
+func (p *pool) submit(fn func(console)) {
+	if p.workers <= 1 {
+		fn(p.out)
+		return
+	}
+	j := &job{fn: fn, done: make(chan struct{})}
+	if p.keepOrder {
+		p.ordered <- j
+	}
+	p.work <- j
+}
+
+// wait blocks until every submitted job has been written.
+func (p *pool) wait() {
+	if p.workers <= 1 {
+		return
+	}
+	close(p.work)
+	p.wg.Wait()
+	if p.keepOrder {
+		close(p.ordered)
+		<-p.flushed
+	}
+}
+
+func (p *pool) flush(j *job) {
+	p.out.stdout.Write(j.stdout.Bytes())
+	p.out.stderr.Write(j.stderr.Bytes())
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// globList is a repeatable flag of glob patterns.
+type globList []string
+
+func (g *globList) String() string {
+	return strings.Join(*g, ",")
+}
+
+func (g *globList) Set(v string) error {
+	for _, p := range strings.Split(v, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		if _, err := filepath.Match(p, ""); err != nil {
+			return err
+		}
+		*g = append(*g, p)
+	}
+	return nil
+}
+
+// match reports whether any pattern matches the base name of p or p relative
+// to the walk root.
+func (g globList) match(rel string) bool {
+	base := filepath.Base(rel)
+	slashed := filepath.ToSlash(rel)
+	for _, pattern := range g {
+		if ok, _ := filepath.Match(pattern, base); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, slashed); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type walkOptions struct {
+	include       globList
+	exclude       globList
+	maxDepth      int // negative means unlimited
+	oneFileSystem bool
+}
+
+// walkTree calls visit for every non-directory entry below root as soon as it
+// is found. Excluded directories are pruned, and include patterns only filter
+// files, so that "*.go" still descends into sub-directories.
+func walkTree(root string, opts walkOptions, visit func(path string), onError func(path string, err error)) {
+	rootDev, haveDev := uint64(0), false
+	if opts.oneFileSystem {
+		rootDev, haveDev = deviceID(root)
+	}
+
+	filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			onError(p, err)
+			return nil
+		}
+		if p == root {
+			return nil
+		}
+
+		rel, rerr := filepath.Rel(root, p)
+		if rerr != nil {
+			rel = p
+		}
+		depth := strings.Count(filepath.ToSlash(rel), "/") + 1
+
+		if d.IsDir() {
+			if opts.exclude.match(rel) {
+				return filepath.SkipDir
+			}
+			if opts.maxDepth >= 0 && depth >= opts.maxDepth {
+				return filepath.SkipDir
+			}
+			if haveDev {
+				if dev, ok := deviceID(p); ok && dev != rootDev {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+
+		if opts.maxDepth >= 0 && depth > opts.maxDepth {
+			return nil
+		}
+		if opts.exclude.match(rel) {
+			return nil
+		}
+		if len(opts.include) > 0 && !opts.include.match(rel) {
+			return nil
+		}
+		visit(p)
+		return nil
+	})
+}